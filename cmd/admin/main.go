@@ -0,0 +1,126 @@
+package main
+
+import (
+	"blog/config"
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"blog/internal/services"
+	"blog/pkg/db"
+	"fmt"
+	"log"
+	"os"
+)
+
+const usage = `usage: go run ./cmd/admin <command> [arguments]
+
+commands:
+  unlock <email>            lift the login and verification lockout of an account
+  unlock-ip <ip>            lift the login and verification lockout of an IP address
+  set-role <email> <role>   change the role of an account (user, moderator or admin)
+  purge-deleted             purge the accounts whose deletion grace period has ended
+  purge-audit               delete the audit events older than the retention period`
+
+// adminClient is recorded as the client of the audit events of this command,
+// which have neither an actor nor an IP address.
+var adminClient = services.ClientInfo{UserAgent: "cmd/admin"}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load configuration from file
+	config.LoadConfig()
+
+	// Connect to the database
+	database, err := db.Connect(config.AppConfig.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database, %s", err)
+	}
+
+	throttleService := services.NewThrottleService(repositories.NewThrottleRepository(database))
+	userRepo := repositories.NewUserRepository(database)
+	auditService := services.NewAuditService(repositories.NewAuditRepository(database))
+
+	command, args := os.Args[1], os.Args[2:]
+	switch {
+	case command == "unlock" && len(args) == 1:
+		var userID uint
+		if user, err := userRepo.GetByEmail(args[0]); err == nil {
+			userID = user.ID
+		}
+		err := throttleService.UnlockAccount(args[0])
+		auditService.Record(services.AuditEntry{
+			Action:     services.AuditUserUnlock,
+			TargetType: services.AuditTargetUser,
+			TargetID:   userID,
+			Identifier: args[0],
+			Client:     adminClient,
+			Err:        err,
+		})
+		if err != nil {
+			log.Fatalf("Failed to unlock account, %s", err)
+		}
+		log.Printf("Unlocked account %s", args[0])
+	case command == "unlock-ip" && len(args) == 1:
+		err := throttleService.UnlockIP(args[0])
+		auditService.Record(services.AuditEntry{
+			Action:     services.AuditIPUnlock,
+			Identifier: args[0],
+			Client:     adminClient,
+			Err:        err,
+		})
+		if err != nil {
+			log.Fatalf("Failed to unlock IP address, %s", err)
+		}
+		log.Printf("Unlocked IP address %s", args[0])
+	case command == "set-role" && len(args) == 2:
+		if !rbac.ValidRole(args[1]) {
+			log.Fatalf("Unknown role %q", args[1])
+		}
+		user, err := userRepo.GetByEmail(args[0])
+		if err != nil {
+			log.Fatalf("Failed to find account, %s", err)
+		}
+		user.Role = args[1]
+		err = userRepo.Update(user)
+		auditService.Record(services.AuditEntry{
+			Action:     services.AuditUserRoleChange,
+			TargetType: services.AuditTargetUser,
+			TargetID:   user.ID,
+			Identifier: args[0],
+			Client:     adminClient,
+			Err:        err,
+		})
+		if err != nil {
+			log.Fatalf("Failed to change role, %s", err)
+		}
+		log.Printf("Account %s is now %s", args[0], args[1])
+	case command == "purge-deleted" && len(args) == 0:
+		accountService := services.NewAccountService(
+			userRepo,
+			repositories.NewPostRepository(database),
+			repositories.NewLikeRepository(database),
+			repositories.NewFollowRepository(database),
+			repositories.NewBlockRepository(database),
+			repositories.NewAccountRepository(database),
+			throttleService,
+			auditService,
+		)
+		purged, err := accountService.PurgeDueAccounts()
+		if err != nil {
+			log.Fatalf("Failed to purge deleted accounts, %s", err)
+		}
+		log.Printf("Purged %d deleted accounts", purged)
+	case command == "purge-audit" && len(args) == 0:
+		deleted, err := auditService.PurgeExpired()
+		if err != nil {
+			log.Fatalf("Failed to delete expired audit events, %s", err)
+		}
+		log.Printf("Deleted %d expired audit events", deleted)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -3,12 +3,18 @@ package main
 import (
 	"blog/config"
 	"blog/internal/handlers"
+	"blog/internal/middleware"
 	"blog/internal/models"
+	"blog/internal/rbac"
 	"blog/internal/repositories"
 	"blog/internal/services"
 	"blog/pkg/db"
+	"blog/pkg/keyring"
+	"blog/pkg/oidc"
+	"blog/pkg/password"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -24,7 +30,7 @@ func main() {
 	}
 
 	// Migrate the database
-	if err := database.AutoMigrate(&models.User{}, &models.Post{}, &models.Like{}); err != nil {
+	if err := database.AutoMigrate(models.All()...); err != nil {
 		log.Fatalf("Failed to migrate database, %s", err)
 	}
 
@@ -39,32 +45,200 @@ func main() {
 		w.Write([]byte("Server is running!"))
 	}).Methods("GET")
 
+	// Create an audit service that records security-relevant actions
+	auditRepo := repositories.NewAuditRepository(database)
+	auditService := services.NewAuditService(auditRepo)
+
+	// Create a token handler
+	keys, err := keyring.New(config.AppConfig.JWT.SigningKeyID, config.AppConfig.JWT.Keys)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys, %s", err)
+	}
+	tokenRepo := repositories.NewTokenRepository(database)
+	tokenService := services.NewTokenService(tokenRepo, keys, auditService)
+	tokenHandler := handlers.NewTokenHandler(tokenService)
+	r.HandleFunc("/token/refresh", tokenHandler.RefreshToken).Methods("POST")
+	r.HandleFunc("/logout", tokenHandler.Logout).Methods("POST")
+	r.HandleFunc("/.well-known/jwks.json", tokenHandler.JWKS).Methods("GET")
+
 	// Create a user handler
+	throttleRepo := repositories.NewThrottleRepository(database)
+	throttleService := services.NewThrottleService(throttleRepo)
 	userRepo := repositories.NewUserRepository(database)
-	userService := services.NewUserService(userRepo)
+	hasher, err := password.New(config.AppConfig.PasswordHashing)
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing, %s", err)
+	}
+	passwordPolicy, err := password.NewPolicy(config.AppConfig.PasswordPolicy)
+	if err != nil {
+		log.Fatalf("Failed to load password policy, %s", err)
+	}
+	userService := services.NewUserService(userRepo, tokenService, throttleService, hasher, passwordPolicy, auditService)
 	userHandler := handlers.NewUserHandler(userService)
 	r.HandleFunc("/register", userHandler.RegisterUser).Methods("POST")
 	r.HandleFunc("/verify", userHandler.VerifyEmail).Methods("POST")
+	r.HandleFunc("/verify/resend", userHandler.ResendVerification).Methods("POST")
+	r.HandleFunc("/password/reset/request", userHandler.RequestPasswordReset).Methods("POST")
+	r.HandleFunc("/password/reset/confirm", userHandler.ConfirmPasswordReset).Methods("POST")
+
+	// Protected routes require a valid access token issued by /login,
+	// and some of them a role that grants the given permission
+	auth := middleware.Auth(userService)
+	protect := func(permission rbac.Permission, handler http.HandlerFunc) http.Handler {
+		return auth(middleware.RequirePermission(permission)(handler))
+	}
+	// Account management is only available to interactive sessions, not to API tokens
+	session := func(handler http.HandlerFunc) http.Handler {
+		return auth(middleware.RequireSession(handler))
+	}
+	// Public routes that change with the caller, such as hiding content from blocked users
+	optional := func(handler http.HandlerFunc) http.Handler {
+		return middleware.OptionalAuth(userService)(handler)
+	}
+	r.Handle("/me/tokens", session(tokenHandler.CreateAPIToken)).Methods("POST")
+	r.Handle("/me/tokens", session(tokenHandler.ListAPITokens)).Methods("GET")
+	r.Handle("/me/tokens/{tokenID}", session(tokenHandler.RevokeAPIToken)).Methods("DELETE")
+
+	// Define routes for login sessions
+	r.Handle("/me/sessions", session(tokenHandler.ListSessions)).Methods("GET")
+	r.Handle("/me/sessions/{sessionID}", session(tokenHandler.RevokeSession)).Methods("DELETE")
+
+	// Create a profile handler
+	followRepo := repositories.NewFollowRepository(database)
+	profileService := services.NewProfileService(userRepo, followRepo)
+	profileHandler := handlers.NewProfileHandler(profileService)
+	r.HandleFunc("/users/{userID:[0-9]+}", profileHandler.GetUser).Methods("GET")
+	r.HandleFunc("/users/@{handle:[A-Za-z0-9_]+}", profileHandler.GetUserByHandle).Methods("GET")
+	r.Handle("/me", auth(http.HandlerFunc(profileHandler.GetMe))).Methods("GET")
+	r.Handle("/me", session(profileHandler.UpdateMe)).Methods("PATCH")
+	r.Handle("/me/handle", session(profileHandler.SetHandle)).Methods("PUT")
+
+	// Create a block handler for blocking and muting users
+	blockRepo := repositories.NewBlockRepository(database)
+	blockService := services.NewBlockService(blockRepo, userRepo)
+	blockHandler := handlers.NewBlockHandler(blockService)
+	r.Handle("/users/{userID:[0-9]+}/block", session(blockHandler.BlockUser)).Methods("POST")
+	r.Handle("/users/{userID:[0-9]+}/block", session(blockHandler.UnblockUser)).Methods("DELETE")
+	r.Handle("/users/{userID:[0-9]+}/mute", session(blockHandler.MuteUser)).Methods("POST")
+	r.Handle("/users/{userID:[0-9]+}/mute", session(blockHandler.UnmuteUser)).Methods("DELETE")
+	r.Handle("/me/blocks", session(blockHandler.ListBlocked)).Methods("GET")
+	r.Handle("/me/mutes", session(blockHandler.ListMuted)).Methods("GET")
+
+	// Create a follow handler
+	followService := services.NewFollowService(followRepo, userRepo, blockRepo)
+	followHandler := handlers.NewFollowHandler(followService)
+	r.Handle("/users/{userID:[0-9]+}/follow", protect(rbac.FollowWrite, followHandler.FollowUser)).Methods("POST")
+	r.Handle("/users/{userID:[0-9]+}/follow", protect(rbac.FollowWrite, followHandler.UnfollowUser)).Methods("DELETE")
+	r.Handle("/users/{userID:[0-9]+}/followers", optional(followHandler.ListFollowers)).Methods("GET")
+	r.Handle("/users/{userID:[0-9]+}/following", optional(followHandler.ListFollowing)).Methods("GET")
+
+	// Create an email change handler
+	emailChangeService := services.NewEmailChangeService(userRepo, tokenService, throttleService, auditService)
+	emailChangeHandler := handlers.NewEmailChangeHandler(emailChangeService)
+	r.Handle("/me/email", session(emailChangeHandler.RequestEmailChange)).Methods("POST")
+	r.Handle("/me/email/confirm", session(emailChangeHandler.ConfirmEmailChange)).Methods("POST")
+	r.HandleFunc("/email/revert", emailChangeHandler.ConfirmRevertEmailChange).Methods("GET")
+	r.HandleFunc("/email/revert", emailChangeHandler.RevertEmailChange).Methods("POST")
 
 	// Create a post handler
 	postRepo := repositories.NewPostRepository(database)
-	postService := services.NewPostService(postRepo)
+	mentionRepo := repositories.NewMentionRepository(database)
+	postService := services.NewPostService(postRepo, userRepo, mentionRepo, blockRepo, auditService)
 	postHandler := handlers.NewPostHandler(postService)
-	r.HandleFunc("/posts/{userID}", postHandler.GetPostsByUserIDHandler).Methods("GET")
-	r.HandleFunc("/posts", postHandler.CreatePostHandler).Methods("POST")
-	r.HandleFunc("/posts/{postID}", postHandler.DeletePostHandler).Methods("DELETE")
+	r.Handle("/posts/{userID}", optional(postHandler.GetPostsByUserIDHandler)).Methods("GET")
+	r.Handle("/me/feed", protect(rbac.PostRead, postHandler.GetFeedHandler)).Methods("GET")
+	r.Handle("/me/mentions", protect(rbac.PostRead, postHandler.GetMentionsHandler)).Methods("GET")
+	r.Handle("/posts/{postID:[0-9]+}/mentions", optional(postHandler.GetMentionedUsersHandler)).Methods("GET")
+	r.Handle("/posts", protect(rbac.PostCreate, postHandler.CreatePostHandler)).Methods("POST")
+	r.Handle("/posts/{postID}", protect(rbac.PostEditOwn, postHandler.UpdatePostHandler)).Methods("PUT", "PATCH")
+	r.Handle("/posts/{postID}", protect(rbac.PostDeleteOwn, postHandler.DeletePostHandler)).Methods("DELETE")
 
 	// Create a like handler
 	likeRepo := repositories.NewLikeRepository(database)
-	likeService := services.NewLikeService(likeRepo)
+	likeService := services.NewLikeService(likeRepo, postRepo, blockRepo, auditService)
 	likeHandler := handlers.NewLikeHandler(likeService)
-	r.HandleFunc("/posts/{postID}/like", likeHandler.AddLikeHandler).Methods("POST")
-	r.HandleFunc("/posts/{postID}/like", likeHandler.RemoveLikeHandler).Methods("DELETE")
+	r.Handle("/posts/{postID}/like", protect(rbac.LikeWrite, likeHandler.AddLikeHandler)).Methods("POST")
+	r.Handle("/posts/{postID}/like", protect(rbac.LikeWrite, likeHandler.RemoveLikeHandler)).Methods("DELETE")
 	r.HandleFunc("/posts/{postID}/likes", likeHandler.GetLikesCounterHandler).Methods("GET")
 
+	// Create an account handler for data exports and account deletion
+	accountRepo := repositories.NewAccountRepository(database)
+	accountService := services.NewAccountService(userRepo, postRepo, likeRepo, followRepo, blockRepo, accountRepo, throttleService, auditService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	r.Handle("/me/export", session(accountHandler.ExportData)).Methods("GET")
+	r.Handle("/me/deletion", session(accountHandler.RequestDeletion)).Methods("POST")
+	r.Handle("/me/deletion/confirm", session(accountHandler.ConfirmDeletion)).Methods("POST")
+	r.Handle("/me/deletion", session(accountHandler.CancelDeletion)).Methods("DELETE")
+
+	// Purge accounts whose deletion grace period has ended
+	go func() {
+		for ; ; time.Sleep(time.Hour) {
+			purged, err := accountService.PurgeDueAccounts()
+			if err != nil {
+				log.Printf("Failed to purge deleted accounts, %s", err)
+			}
+			if purged > 0 {
+				log.Printf("Purged %d deleted accounts", purged)
+			}
+		}
+	}()
+
 	// Add a login endpoint
 	r.HandleFunc("/login", userHandler.LoginUser).Methods("POST")
 
+	// Create a magic link handler for passwordless login
+	magicLinkService := services.NewMagicLinkService(userRepo, tokenService, userService)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
+	r.HandleFunc("/login/magic", magicLinkHandler.RequestMagicLink).Methods("POST")
+	r.HandleFunc("/login/magic/verify", magicLinkHandler.ConfirmMagicLink).Methods("GET")
+	r.HandleFunc("/login/magic/verify", magicLinkHandler.VerifyMagicLink).Methods("POST")
+
+	// Create a two-factor authentication handler
+	mfaRepo := repositories.NewMFARepository(database)
+	mfaService := services.NewMFAService(userRepo, mfaRepo, tokenService, throttleService, auditService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	r.HandleFunc("/login/mfa", mfaHandler.LoginMFA).Methods("POST")
+	r.Handle("/me/mfa/totp", session(mfaHandler.EnrollTOTP)).Methods("POST")
+	r.Handle("/me/mfa/totp/confirm", session(mfaHandler.ConfirmTOTP)).Methods("POST")
+	r.Handle("/me/mfa/totp", session(mfaHandler.DisableTOTP)).Methods("DELETE")
+	r.Handle("/me/mfa/recovery-codes", session(mfaHandler.RegenerateRecoveryCodes)).Methods("POST")
+
+	// Create an OpenID Connect handler
+	providers := make(map[string]*oidc.Provider)
+	for _, providerConfig := range config.AppConfig.OIDC.Providers {
+		providers[providerConfig.Name] = oidc.NewProvider(providerConfig)
+	}
+	oidcRepo := repositories.NewOIDCRepository(database)
+	oidcService := services.NewOIDCService(providers, oidcRepo, userRepo, userService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	r.HandleFunc("/auth/{provider}/login", oidcHandler.Login).Methods("GET")
+	r.HandleFunc("/auth/{provider}/callback", oidcHandler.Callback).Methods("GET")
+
+	// Create a moderation handler
+	moderationService := services.NewModerationService(userRepo, tokenService, throttleService, auditService)
+	moderationHandler := handlers.NewModerationHandler(moderationService)
+	r.Handle("/users/{userID:[0-9]+}/ban", protect(rbac.UserBan, moderationHandler.BanUser)).Methods("POST")
+	r.Handle("/users/{userID:[0-9]+}/ban", protect(rbac.UserBan, moderationHandler.UnbanUser)).Methods("DELETE")
+	r.Handle("/users/{userID:[0-9]+}/role", protect(rbac.UserSetRole, moderationHandler.SetRole)).Methods("PUT")
+	r.Handle("/users/{userID:[0-9]+}/unlock", protect(rbac.UserUnlock, moderationHandler.UnlockUser)).Methods("POST")
+
+	// Create an audit handler for admins
+	auditHandler := handlers.NewAuditHandler(auditService)
+	r.Handle("/admin/audit-events", protect(rbac.AuditRead, auditHandler.ListAuditEvents)).Methods("GET")
+
+	// Delete audit events past the retention period
+	go func() {
+		for ; ; time.Sleep(time.Hour) {
+			deleted, err := auditService.PurgeExpired()
+			if err != nil {
+				log.Printf("Failed to delete expired audit events, %s", err)
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired audit events", deleted)
+			}
+		}
+	}()
+
 	log.Println("Server is running on port " + config.AppConfig.Server.Port)
 	if err := http.ListenAndServe(":8080", r); err != nil {
 		log.Fatalf("Failed to start server, %s", err)
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"blog/internal/handlers"
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"blog/internal/services"
+	"blog/internal/testutil"
+	"blog/pkg/oidc"
+	"blog/pkg/password"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// newLoginServers starts the stub provider and a blog server that logs in with it,
+// and returns the blog's URL and database.
+func newLoginServers(t *testing.T) (blogURL string, db *gorm.DB) {
+	t.Helper()
+	idp := httptest.NewUnstartedServer(nil)
+	provider, err := newStubProvider("http://"+idp.Listener.Addr().String(), "blog", "blog-secret", "stub.user@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.Config.Handler = provider.routes()
+	idp.Start()
+	t.Cleanup(idp.Close)
+
+	blog := httptest.NewUnstartedServer(nil)
+	blogURL = "http://" + blog.Listener.Addr().String()
+
+	db = testutil.OpenDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	auditService := services.NewAuditService(repositories.NewAuditRepository(db))
+	tokenService := services.NewTokenService(repositories.NewTokenRepository(db), testutil.Keyring(t), auditService)
+	throttleService := services.NewThrottleService(repositories.NewThrottleRepository(db))
+	hasher, err := password.New(password.Config{Algorithm: password.AlgorithmBcrypt, Bcrypt: password.BcryptConfig{Cost: bcrypt.MinCost}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := password.NewPolicy(password.PolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := services.NewUserService(userRepo, tokenService, throttleService, hasher, policy, auditService)
+	providers := map[string]*oidc.Provider{"stub": oidc.NewProvider(oidc.ProviderConfig{
+		Name:         "stub",
+		Issuer:       provider.issuer,
+		ClientID:     "blog",
+		ClientSecret: "blog-secret",
+		RedirectURL:  blogURL + "/auth/stub/callback",
+	})}
+	oidcHandler := handlers.NewOIDCHandler(services.NewOIDCService(providers, repositories.NewOIDCRepository(db), userRepo, userService))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/auth/{provider}/login", oidcHandler.Login).Methods("GET")
+	r.HandleFunc("/auth/{provider}/callback", oidcHandler.Callback).Methods("GET")
+	blog.Config.Handler = r
+	blog.Start()
+	t.Cleanup(blog.Close)
+	return blogURL, db
+}
+
+// newBrowser returns a client that keeps cookies and stops at redirects, so each step can be checked.
+func newBrowser(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// get requests url and returns the response status and the Location header.
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Location")
+}
+
+// login signs in with the stub provider in a new browser and returns the status of the callback.
+func login(t *testing.T, blogURL string) int {
+	t.Helper()
+	browser := newBrowser(t)
+	status, _ := get(t, browser, startLogin(t, browser, blogURL))
+	return status
+}
+
+// startLogin starts a login in browser and returns the callback URL the provider redirects it to.
+func startLogin(t *testing.T, browser *http.Client, blogURL string) string {
+	t.Helper()
+	status, authURL := get(t, browser, blogURL+"/auth/stub/login")
+	if status != http.StatusFound {
+		t.Fatalf("login returned %d, want 302", status)
+	}
+	status, callbackURL := get(t, browser, authURL)
+	if status != http.StatusFound {
+		t.Fatalf("provider returned %d, want 302", status)
+	}
+	return callbackURL
+}
+
+func TestCallbackOnlyCompletesInTheBrowserThatStartedTheLogin(t *testing.T) {
+	blogURL, _ := newLoginServers(t)
+	browser := newBrowser(t)
+	callbackURL := startLogin(t, browser, blogURL)
+
+	// Someone who got hold of the callback URL cannot redeem it without the browser's cookie
+	if status, _ := get(t, newBrowser(t), callbackURL); status != http.StatusBadRequest {
+		t.Errorf("callback replayed without the cookie returned %d, want 400", status)
+	}
+
+	// Nor can a victim be made to open it, which would sign them into someone else's account
+	victim := newBrowser(t)
+	startLogin(t, victim, blogURL)
+	if status, _ := get(t, victim, callbackURL); status != http.StatusBadRequest {
+		t.Errorf("callback opened in a browser with another login returned %d, want 400", status)
+	}
+
+	// The rejected attempts did not use the login up
+	resp, err := browser.Get(callbackURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("callback in the browser that started the login returned %d, want 200", resp.StatusCode)
+	}
+	var result services.LoginResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.TokenPair == nil || result.AccessToken == "" {
+		t.Errorf("callback returned %+v, want a token pair", result)
+	}
+
+	// The state is single-use, even in the right browser
+	if status, _ := get(t, browser, callbackURL); status != http.StatusBadRequest {
+		t.Errorf("second callback returned %d, want 400", status)
+	}
+}
+
+func TestLoginAfterTheLinkedAccountIsGone(t *testing.T) {
+	blogURL, db := newLoginServers(t)
+	if status := login(t, blogURL); status != http.StatusOK {
+		t.Fatalf("first login returned %d, want 200", status)
+	}
+
+	// Leave the identity behind, as if the account had been removed without it
+	var user models.User
+	if err := db.Where("email = ?", "stub.user@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Unscoped().Delete(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if status := login(t, blogURL); status != http.StatusOK {
+		t.Fatalf("login after the account was removed returned %d, want 200", status)
+	}
+	var identities []models.UserIdentity
+	if err := db.Find(&identities).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].UserID == user.ID {
+		t.Errorf("identities = %+v, want one linked to a new account", identities)
+	}
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"blog/pkg/keyring"
+	"blog/pkg/oidc"
+	"blog/pkg/password"
 	"log"
 
 	"github.com/spf13/viper"
@@ -10,6 +13,8 @@ type Config struct {
 	Server struct {
 		Host string
 		Port string
+		// PublicURL is where clients reach the server, used for links in emails.
+		PublicURL string `mapstructure:"public_url"`
 	}
 	Database struct {
 		Host     string
@@ -20,8 +25,27 @@ type Config struct {
 		SSLMode  string
 	}
 	JWT struct {
-		SecretKey            string
-		TokenLifetimeMinutes int
+		SecretKey                   string `mapstructure:"secret_key"`
+		SigningKeyID                string `mapstructure:"signing_key_id"`
+		Keys                        []keyring.KeyConfig
+		TokenLifetimeMinutes        int `mapstructure:"token_lifetime_minutes"`
+		RefreshTokenLifetimeMinutes int `mapstructure:"refresh_token_lifetime_minutes"`
+	}
+	PasswordHashing password.Config       `mapstructure:"password_hashing"`
+	PasswordPolicy  password.PolicyConfig `mapstructure:"password_policy"`
+	OIDC            struct {
+		Providers []oidc.ProviderConfig
+	}
+	// AccountDeletion decides what happens to a deleted account's content. Posts and Likes
+	// are "delete" to remove them or "anonymize" to keep them under a scrubbed user.
+	AccountDeletion struct {
+		GracePeriodDays int `mapstructure:"grace_period_days"`
+		Posts           string
+		Likes           string
+	} `mapstructure:"account_deletion"`
+	// Audit events older than RetentionDays are deleted; zero keeps them forever.
+	Audit struct {
+		RetentionDays int `mapstructure:"retention_days"`
 	}
 	Email struct {
 		SMTPServer string
@@ -44,4 +68,18 @@ func LoadConfig() {
 	if err := viper.Unmarshal(&AppConfig); err != nil {
 		log.Fatalf("Unable to decode into struct, %v", err)
 	}
+	// A plain secret_key is treated as a keyring with a single HS256 key
+	if len(AppConfig.JWT.Keys) == 0 && AppConfig.JWT.SecretKey != "" {
+		AppConfig.JWT.Keys = []keyring.KeyConfig{{
+			ID:        "default",
+			Algorithm: "HS256",
+			Status:    keyring.StatusActive,
+			Secret:    AppConfig.JWT.SecretKey,
+		}}
+	}
+	for _, policy := range []string{AppConfig.AccountDeletion.Posts, AppConfig.AccountDeletion.Likes} {
+		if policy != "" && policy != "delete" && policy != "anonymize" {
+			log.Fatalf("Unknown account deletion policy %q, expected delete or anonymize", policy)
+		}
+	}
 }
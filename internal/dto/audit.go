@@ -0,0 +1,42 @@
+package dto
+
+import "blog/internal/models"
+
+// AuditEvent is an entry of the audit log. ActorID and TargetID are null when unknown.
+type AuditEvent struct {
+	ID         uint   `json:"id"`
+	ActorID    *uint  `json:"actor_id"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type,omitempty"`
+	TargetID   *uint  `json:"target_id"`
+	Identifier string `json:"identifier,omitempty"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Outcome    string `json:"outcome"`
+	Reason     string `json:"reason,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func NewAuditEvent(event *models.AuditEvent) AuditEvent {
+	return AuditEvent{
+		ID:         event.ID,
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Identifier: event.Identifier,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		Outcome:    event.Outcome,
+		Reason:     event.Reason,
+		CreatedAt:  timestamp(event.CreatedAt),
+	}
+}
+
+func NewAuditEvents(events []models.AuditEvent) []AuditEvent {
+	views := make([]AuditEvent, len(events))
+	for i := range events {
+		views[i] = NewAuditEvent(&events[i])
+	}
+	return views
+}
@@ -0,0 +1,18 @@
+// Package dto holds the API representation of the storage models. Handlers encode these
+// types instead of the models, so every field a client sees is mapped here explicitly:
+// names are snake_case and timestamps are RFC 3339 strings in UTC.
+package dto
+
+import "time"
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func optionalTimestamp(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := timestamp(*t)
+	return &s
+}
@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"blog/internal/models"
+	"time"
+)
+
+// Relation is another user the exported user is connected to, and since when.
+type Relation struct {
+	UserID    uint   `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+func NewRelation(userID uint, createdAt time.Time) Relation {
+	return Relation{UserID: userID, CreatedAt: timestamp(createdAt)}
+}
+
+// PreviousHandle is a handle the user has renamed away from. It redirects to them until
+// RedirectsUntil.
+type PreviousHandle struct {
+	Handle         string `json:"handle"`
+	ChangedAt      string `json:"changed_at"`
+	RedirectsUntil string `json:"redirects_until"`
+}
+
+func NewPreviousHandles(redirects []models.HandleRedirect) []PreviousHandle {
+	views := make([]PreviousHandle, len(redirects))
+	for i, redirect := range redirects {
+		views[i] = PreviousHandle{
+			Handle:         redirect.Handle,
+			ChangedAt:      timestamp(redirect.CreatedAt),
+			RedirectsUntil: timestamp(redirect.ExpiresAt),
+		}
+	}
+	return views
+}
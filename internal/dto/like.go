@@ -0,0 +1,23 @@
+package dto
+
+import "blog/internal/models"
+
+// Like is a like the user has given.
+type Like struct {
+	PostID    uint   `json:"post_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+func NewLikes(likes []models.Like) []Like {
+	views := make([]Like, len(likes))
+	for i, like := range likes {
+		views[i] = Like{PostID: like.PostID, CreatedAt: timestamp(like.CreatedAt)}
+	}
+	return views
+}
+
+// LikeCount is the number of likes of a post.
+type LikeCount struct {
+	PostID uint  `json:"post_id"`
+	Likes  int64 `json:"likes"`
+}
@@ -0,0 +1,31 @@
+package dto
+
+import "blog/internal/models"
+
+type Post struct {
+	ID        uint   `json:"id"`
+	UserID    uint   `json:"user_id"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func NewPost(post *models.Post) Post {
+	return Post{
+		ID:        post.ID,
+		UserID:    post.UserID,
+		Title:     post.Title,
+		Content:   post.Content,
+		CreatedAt: timestamp(post.CreatedAt),
+		UpdatedAt: timestamp(post.UpdatedAt),
+	}
+}
+
+func NewPosts(posts []models.Post) []Post {
+	views := make([]Post, len(posts))
+	for i := range posts {
+		views[i] = NewPost(&posts[i])
+	}
+	return views
+}
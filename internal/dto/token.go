@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"blog/internal/models"
+	"strings"
+)
+
+// APIToken describes a personal access token. The secret is never included.
+type APIToken struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// CreatedAPIToken is returned once, when the token is created; Token is the secret itself.
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// Session is a login of the user on a device. Current marks the session of the request.
+type Session struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
+
+func NewAPIToken(token *models.APIToken) APIToken {
+	return APIToken{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Fields(token.Scopes),
+		ExpiresAt:  timestamp(token.ExpiresAt),
+		LastUsedAt: optionalTimestamp(token.LastUsedAt),
+		CreatedAt:  timestamp(token.CreatedAt),
+	}
+}
+
+func NewAPITokens(tokens []models.APIToken) []APIToken {
+	views := make([]APIToken, len(tokens))
+	for i := range tokens {
+		views[i] = NewAPIToken(&tokens[i])
+	}
+	return views
+}
+
+func NewCreatedAPIToken(token *models.APIToken, secret string) CreatedAPIToken {
+	return CreatedAPIToken{APIToken: NewAPIToken(token), Token: secret}
+}
+
+// NewSessions maps the sessions and marks the one with currentID.
+func NewSessions(sessions []models.Session, currentID string) []Session {
+	views := make([]Session, len(sessions))
+	for i, session := range sessions {
+		views[i] = Session{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  timestamp(session.CreatedAt),
+			LastSeenAt: timestamp(session.LastSeenAt),
+			Current:    session.ID == currentID,
+		}
+	}
+	return views
+}
@@ -0,0 +1,79 @@
+package dto
+
+import "blog/internal/models"
+
+// User is the public view of a user. It never includes the email address or any credentials.
+type User struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Handle      *string `json:"handle"`
+	DisplayName string  `json:"display_name"`
+	Bio         string  `json:"bio"`
+	Website     string  `json:"website"`
+	AvatarURL   string  `json:"avatar_url"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// Profile is the public profile of a user with their follower and following counts.
+type Profile struct {
+	User
+	FollowersCount int64 `json:"followers_count"`
+	FollowingCount int64 `json:"following_count"`
+}
+
+// Me is the view of a user for the user themselves.
+type Me struct {
+	User
+	Email       string `json:"email"`
+	IsVerified  bool   `json:"is_verified"`
+	Role        string `json:"role"`
+	TOTPEnabled bool   `json:"totp_enabled"`
+	UpdatedAt   string `json:"updated_at"`
+	// PendingEmail is the new address while an email change waits for its code.
+	PendingEmail string `json:"pending_email"`
+	// DeletionScheduledAt is set while a confirmed account deletion is pending.
+	DeletionScheduledAt *string `json:"deletion_scheduled_at"`
+}
+
+func NewUser(user *models.User) User {
+	return User{
+		ID:          user.ID,
+		Name:        user.Name,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Website:     user.Website,
+		AvatarURL:   user.AvatarURL,
+		CreatedAt:   timestamp(user.CreatedAt),
+	}
+}
+
+func NewProfile(user *models.User, followers, following int64) Profile {
+	return Profile{
+		User:           NewUser(user),
+		FollowersCount: followers,
+		FollowingCount: following,
+	}
+}
+
+func NewUsers(users []models.User) []User {
+	views := make([]User, len(users))
+	for i := range users {
+		views[i] = NewUser(&users[i])
+	}
+	return views
+}
+
+func NewMe(user *models.User) Me {
+	return Me{
+		User:        NewUser(user),
+		Email:       user.Email,
+		IsVerified:  user.IsVerified,
+		Role:        user.Role,
+		TOTPEnabled: user.TOTPEnabled,
+		UpdatedAt:   timestamp(user.UpdatedAt),
+
+		PendingEmail:        user.PendingEmail,
+		DeletionScheduledAt: optionalTimestamp(user.DeletionScheduledAt),
+	}
+}
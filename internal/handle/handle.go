@@ -0,0 +1,94 @@
+// Package handle defines the rules for user handles and finds @handle mentions in text.
+// Handles are case-insensitive, so they are compared and stored in lowercase.
+package handle
+
+import "strings"
+
+const (
+	MinLength = 3
+	MaxLength = 30
+
+	// MaxMentions caps how many users a single text can mention.
+	MaxMentions = 20
+)
+
+// Error explains why a handle was rejected. Code is machine-readable.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "handle " + e.Message
+}
+
+// reserved holds handles that could be mistaken for the site itself or for routes.
+var reserved = map[string]bool{
+	"admin": true, "administrator": true, "moderator": true, "mod": true, "staff": true,
+	"support": true, "help": true, "security": true, "abuse": true, "root": true,
+	"system": true, "official": true, "blog": true, "api": true, "auth": true,
+	"login": true, "logout": true, "register": true, "signup": true, "settings": true,
+	"me": true, "users": true, "posts": true, "feed": true, "everyone": true,
+	"here": true, "all": true, "null": true, "undefined": true, "anonymous": true,
+}
+
+// Normalize returns the form a handle is stored and compared in. A leading "@" is dropped.
+func Normalize(h string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), "@"))
+}
+
+// Validate returns an *Error if the normalized handle may not be used: it must be 3 to 30
+// letters, digits or underscores, contain at least one letter so it cannot be confused
+// with a user ID, and not be reserved.
+func Validate(h string) error {
+	if len(h) < MinLength || len(h) > MaxLength {
+		return &Error{"invalid_length", "must be 3 to 30 characters"}
+	}
+	hasLetter := false
+	for i := 0; i < len(h); i++ {
+		c := h[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			hasLetter = true
+		case c >= '0' && c <= '9', c == '_':
+		default:
+			return &Error{"invalid_characters", "may only contain letters, digits and underscores"}
+		}
+	}
+	if !hasLetter {
+		return &Error{"invalid_characters", "must contain a letter"}
+	}
+	if reserved[h] {
+		return &Error{"reserved", "is reserved"}
+	}
+	return nil
+}
+
+// Mentions returns the distinct normalized handles mentioned as @handle in text, in order of
+// appearance and at most MaxMentions of them. An "@" preceded by a letter, digit, "_", "." or
+// another "@" is not a mention, so email addresses are skipped.
+func Mentions(text string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(text) && len(mentions) < MaxMentions; i++ {
+		if text[i] != '@' || (i > 0 && (isHandleChar(text[i-1]) || text[i-1] == '.' || text[i-1] == '@')) {
+			continue
+		}
+		end := i + 1
+		for end < len(text) && isHandleChar(text[end]) {
+			end++
+		}
+		h := strings.ToLower(text[i+1 : end])
+		i = end - 1
+		if Validate(h) != nil || seen[h] {
+			continue
+		}
+		seen[h] = true
+		mentions = append(mentions, h)
+	}
+	return mentions
+}
+
+func isHandleChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
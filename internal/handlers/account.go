@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"archive/zip"
+	"blog/internal/dto"
+	"blog/internal/middleware"
+	"blog/internal/services"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type AccountHandler struct {
+	AccountService *services.AccountService
+}
+
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{AccountService: accountService}
+}
+
+// ExportData handles the HTTP GET request to download the caller's data.
+//
+// It returns a 200 OK response with a ZIP archive that contains profile.json, posts.json,
+// likes.json (the likes the caller has given), following.json, followers.json, blocks.json and
+// mutes.json (the users the caller has blocked or muted), handles.json (previous handles),
+// and every post as Markdown under posts/.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to export data.
+func (h *AccountHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := h.AccountService.Export(userID)
+	switch err {
+	case nil:
+	case services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+
+	// The archive is built in memory so a failure can still be reported as an error response
+	var archive bytes.Buffer
+	if err := writeExportArchive(&archive, export); err != nil {
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="blog-export-%d.zip"`, userID))
+	w.Write(archive.Bytes())
+}
+
+// RequestDeletion handles the HTTP POST request to start deleting the caller's account.
+//
+// A confirmation code is emailed to the caller, and it returns a 202 Accepted response.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to send confirmation code.
+func (h *AccountHandler) RequestDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.AccountService.RequestDeletion(userID)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to send confirmation code", http.StatusInternalServerError)
+	}
+}
+
+// ConfirmDeletion handles the HTTP POST request to confirm the deletion of the caller's account.
+//
+// It expects a JSON parameter "token" (the emailed code). The account is deleted after the
+// grace period unless the deletion is cancelled; it returns a 200 OK response with the
+// "deletion_scheduled_at" time.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, invalid, used or expired code.
+// 401 Unauthorized: Missing or invalid token.
+// 422 Unprocessable Entity: Missing token.
+// 500 Internal Server Error: Failed to schedule deletion.
+func (h *AccountHandler) ConfirmDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var confirmReq struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if !decodeJSON(w, r, &confirmReq) {
+		return
+	}
+
+	scheduledAt, err := h.AccountService.ConfirmDeletion(userID, confirmReq.Token, clientInfo(r))
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(map[string]string{
+			"deletion_scheduled_at": scheduledAt.UTC().Format(time.RFC3339),
+		})
+	case services.ErrInvalidDeletionToken:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to schedule deletion", http.StatusInternalServerError)
+	}
+}
+
+// CancelDeletion handles the HTTP DELETE request to cancel a scheduled account deletion.
+//
+// If the deletion is cancelled, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: No deletion is scheduled.
+// 500 Internal Server Error: Failed to cancel deletion.
+func (h *AccountHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.AccountService.CancelDeletion(userID, clientInfo(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrDeletionNotScheduled, services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to cancel deletion", http.StatusInternalServerError)
+	}
+}
+
+func writeExportArchive(buf *bytes.Buffer, export *services.AccountExport) error {
+	archive := zip.NewWriter(buf)
+
+	following, followers := []dto.Relation{}, []dto.Relation{}
+	for _, follow := range export.Follows {
+		if follow.FollowerID == export.User.ID {
+			following = append(following, dto.NewRelation(follow.FolloweeID, follow.CreatedAt))
+		} else {
+			followers = append(followers, dto.NewRelation(follow.FollowerID, follow.CreatedAt))
+		}
+	}
+
+	blocks := make([]dto.Relation, len(export.Blocks))
+	for i, block := range export.Blocks {
+		blocks[i] = dto.NewRelation(block.BlockedID, block.CreatedAt)
+	}
+	mutes := make([]dto.Relation, len(export.Mutes))
+	for i, mute := range export.Mutes {
+		mutes[i] = dto.NewRelation(mute.MutedID, mute.CreatedAt)
+	}
+
+	files := map[string]interface{}{
+		"profile.json":   dto.NewMe(export.User),
+		"posts.json":     dto.NewPosts(export.Posts),
+		"likes.json":     dto.NewLikes(export.Likes),
+		"following.json": following,
+		"followers.json": followers,
+		"blocks.json":    blocks,
+		"mutes.json":     mutes,
+		"handles.json":   dto.NewPreviousHandles(export.PreviousHandles),
+	}
+	for _, name := range []string{"profile.json", "posts.json", "likes.json", "following.json", "followers.json",
+		"blocks.json", "mutes.json", "handles.json"} {
+		file, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(files[name]); err != nil {
+			return err
+		}
+	}
+
+	for _, post := range export.Posts {
+		file, err := archive.Create(fmt.Sprintf("posts/%d.md", post.ID))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(file, "# %s\n\n_Published %s_\n\n%s\n",
+			post.Title, post.CreatedAt.UTC().Format(time.RFC3339), post.Content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"blog/internal/dto"
+	"blog/internal/repositories"
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AuditHandler struct {
+	AuditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{AuditService: auditService}
+}
+
+// ListAuditEvents handles the HTTP GET request to search the audit log.
+//
+// It accepts the optional query parameters "user_id" (events performed by or targeting the user),
+// "identifier" (the email given in a login attempt), "action", "from" and "to" (RFC 3339 times, "to" is exclusive), "limit" (default 50, at most 200)
+// and "offset". It returns a JSON array of events, newest first.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid query parameter.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: Missing permission.
+// 500 Internal Server Error: Failed to list audit events.
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid query parameter", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.AuditService.ListEvents(filter)
+	if err != nil {
+		http.Error(w, "Failed to list audit events", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewAuditEvents(events))
+}
+
+func parseAuditFilter(query url.Values) (repositories.AuditFilter, error) {
+	filter := repositories.AuditFilter{Identifier: query.Get("identifier"), Action: query.Get("action")}
+	if v := query.Get("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, err
+		}
+		filter.UserID = uint(userID)
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, err
+			}
+			*t = parsed
+		}
+	}
+	var err error
+	filter.Limit, filter.Offset, err = parsePage(query)
+	return filter, err
+}
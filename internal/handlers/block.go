@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"blog/internal/dto"
+	"blog/internal/middleware"
+	"blog/internal/models"
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type BlockHandler struct {
+	BlockService *services.BlockService
+}
+
+func NewBlockHandler(blockService *services.BlockService) *BlockHandler {
+	return &BlockHandler{BlockService: blockService}
+}
+
+// BlockUser handles the HTTP POST request to block a user.
+//
+// It expects the ID of the user to block as a path parameter. The blocked user can no longer
+// like the caller's posts, follow the caller, or list the caller's posts and followers, and
+// any follow between the two is removed. If the user is blocked, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID, or the user is the caller.
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: User not found.
+// 409 Conflict: The user is already blocked.
+// 500 Internal Server Error: Failed to block user.
+func (h *BlockHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	h.update(w, r, h.BlockService.Block)
+}
+
+// UnblockUser handles the HTTP DELETE request to unblock a user.
+//
+// It expects the ID of the blocked user as a path parameter. If the user is unblocked,
+// it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID.
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: The user is not blocked.
+// 500 Internal Server Error: Failed to unblock user.
+func (h *BlockHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	h.update(w, r, h.BlockService.Unblock)
+}
+
+// MuteUser handles the HTTP POST request to mute a user.
+//
+// It expects the ID of the user to mute as a path parameter. Posts of muted users are left
+// out of the caller's feed; the muted user is not told. It responds like BlockUser.
+func (h *BlockHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	h.update(w, r, h.BlockService.Mute)
+}
+
+// UnmuteUser handles the HTTP DELETE request to unmute a user and responds like UnblockUser.
+func (h *BlockHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	h.update(w, r, h.BlockService.Unmute)
+}
+
+// ListBlocked handles the HTTP GET request to list the users the caller has blocked.
+//
+// It accepts the optional query parameters "limit" (default 50, at most 200) and "offset",
+// and returns a JSON array of public profiles, most recently blocked first.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid limit or offset.
+// 401 Unauthorized: Missing or invalid token.
+// 500 Internal Server Error: Failed to list users.
+func (h *BlockHandler) ListBlocked(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.BlockService.ListBlocked)
+}
+
+// ListMuted handles the HTTP GET request to list the users the caller has muted
+// and responds like ListBlocked.
+func (h *BlockHandler) ListMuted(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.BlockService.ListMuted)
+}
+
+// update runs action for the authenticated user and the user in the path.
+func (h *BlockHandler) update(w http.ResponseWriter, r *http.Request, action func(userID, otherID uint) error) {
+	otherID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = action(userID, uint(otherID))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrSelfBlock:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrUNF:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case services.ErrNotBlocked, services.ErrNotMuted:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrAlreadyBlocked, services.ErrAlreadyMuted:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+	}
+}
+
+func (h *BlockHandler) list(w http.ResponseWriter, r *http.Request, list func(userID uint, limit, offset int) ([]models.User, error)) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, err := list(userID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewUsers(users))
+}
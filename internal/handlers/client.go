@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"blog/internal/middleware"
+	"blog/internal/services"
+)
+
+// clientIP returns the address of the directly connected client.
+func clientIP(r *http.Request) string {
+	return middleware.ClientInfo(r).IP
+}
+
+// clientInfo returns the address and user agent of the client, recorded on new sessions.
+func clientInfo(r *http.Request) services.ClientInfo {
+	return middleware.ClientInfo(r)
+}
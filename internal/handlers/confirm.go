@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// confirmPage asks the user to confirm the action of an emailed link. The link itself only
+// shows this page, so mail scanners and link previews that open it change nothing.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="referrer" content="no-referrer"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+// writeConfirmPage responds with a page whose button POSTs the "token" query parameter back
+// to the same path.
+func writeConfirmPage(w http.ResponseWriter, r *http.Request, title, button string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Frame-Options", "DENY")
+	confirmPage.Execute(w, map[string]string{
+		"Title":  title,
+		"Button": button,
+		"Action": r.URL.Path,
+		"Token":  r.URL.Query().Get("token"),
+	})
+}
+
+// formToken returns the "token" of a form submitted from a confirm page.
+func formToken(w http.ResponseWriter, r *http.Request) string {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	return r.PostFormValue("token")
+}
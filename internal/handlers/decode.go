@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"blog/internal/validation"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxRequestBodySize caps JSON request bodies; no endpoint needs more than a post's content.
+const maxRequestBodySize = 1 << 20
+
+var errTrailingData = errors.New("request body must contain a single JSON value")
+
+// decodeJSON strictly decodes the request body into dst: unknown fields, trailing data and
+// bodies over maxRequestBodySize are rejected. dst is then checked against its `validate` tags.
+// On failure it writes a 400 Bad Request, 413 Request Entity Too Large or
+// 422 Unprocessable Entity response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errTrailingData
+	}
+
+	if err == nil {
+		err = validation.Struct(dst)
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var validationErrs validation.Errors
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &validationErrs):
+		writeValidationError(w, validationErrs)
+	case errors.As(err, &maxBytesErr):
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+	}
+	return false
+}
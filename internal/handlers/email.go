@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"blog/internal/middleware"
+	"blog/internal/services"
+	"net/http"
+)
+
+type EmailChangeHandler struct {
+	EmailChangeService *services.EmailChangeService
+}
+
+func NewEmailChangeHandler(emailChangeService *services.EmailChangeService) *EmailChangeHandler {
+	return &EmailChangeHandler{EmailChangeService: emailChangeService}
+}
+
+// RequestEmailChange handles the HTTP POST request to change the caller's email address.
+//
+// It expects a JSON parameter "email" with the new address, which receives a verification code.
+// The current address stays in effect until the code is confirmed. It returns a 202 Accepted response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, or the new address is the current one.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: The current address has not been verified yet, with {"code": "email_not_verified"}.
+// 409 Conflict: Email already exists.
+// 422 Unprocessable Entity: Missing or invalid email.
+// 429 Too Many Requests: A code was sent less than a minute ago.
+// 500 Internal Server Error: Failed to send verification email.
+func (h *EmailChangeHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var changeReq struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+	if !decodeJSON(w, r, &changeReq) {
+		return
+	}
+
+	err := h.EmailChangeService.RequestChange(userID, changeReq.Email)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case services.ErrSameEmail:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrEmailNotVerified:
+		writeError(w, http.StatusForbidden, "email_not_verified", "Email address has not been verified")
+	case services.ErrEmailTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrResendTooSoon:
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+	}
+}
+
+// ConfirmEmailChange handles the HTTP POST request to confirm a new email address.
+//
+// It expects a JSON parameter "code" with the code sent to the new address. If it matches,
+// the new address replaces the old one, the old address is sent a link that reverts the change,
+// and it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, no change pending, invalid or expired code.
+// 401 Unauthorized: Missing or invalid token.
+// 409 Conflict: The new address has been registered in the meantime.
+// 422 Unprocessable Entity: Missing code.
+// 429 Too Many Requests: Too many wrong codes; the change must be requested again,
+// or too many failed attempts for the account or IP address, see Retry-After.
+// 500 Internal Server Error: Failed to change email.
+func (h *EmailChangeHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var confirmReq struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if !decodeJSON(w, r, &confirmReq) {
+		return
+	}
+
+	err := h.EmailChangeService.ConfirmChange(userID, confirmReq.Code, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrNoPendingEmail, services.ErrIVC, services.ErrVerificationExpired:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrEmailTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrTooManyAttempts:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Failed to change email", http.StatusInternalServerError)
+	}
+}
+
+// ConfirmRevertEmailChange handles the HTTP GET request from the link sent to the previous
+// address after an email change.
+//
+// It expects a "token" query parameter and returns an HTML page that asks the user to confirm,
+// which POSTs the token to RevertEmailChange. Opening the link changes nothing.
+func (h *EmailChangeHandler) ConfirmRevertEmailChange(w http.ResponseWriter, r *http.Request) {
+	writeConfirmPage(w, r, "Restore your email address", "Restore email address and sign out everywhere")
+}
+
+// RevertEmailChange handles the HTTP POST request to undo an email change.
+//
+// It expects a form parameter "token" from the revert link. The previous address is restored,
+// every session is signed out, and it returns a 200 OK response with a plain text confirmation.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid, used or expired link.
+// 409 Conflict: The previous address now belongs to another account.
+// 500 Internal Server Error: Failed to restore email.
+func (h *EmailChangeHandler) RevertEmailChange(w http.ResponseWriter, r *http.Request) {
+	err := h.EmailChangeService.RevertChange(formToken(w, r), clientInfo(r))
+	switch err {
+	case nil:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("Your email address has been restored and you have been signed out everywhere. Please reset your password.\n"))
+	case services.ErrInvalidRevertToken:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrEmailTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to restore email", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"blog/internal/handle"
+	"blog/internal/services"
+	"blog/internal/validation"
+	"blog/pkg/password"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// writeError responds with a JSON error that carries a machine-readable code,
+// for failures clients are expected to handle rather than just display.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"code": code, "error": message})
+}
+
+// writeValidationError responds with 422 Unprocessable Entity and the list of invalid fields,
+// each as {"field", "code", "message"}.
+func writeValidationError(w http.ResponseWriter, errs validation.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":   "validation_failed",
+		"error":  "Invalid request fields",
+		"errors": errs,
+	})
+}
+
+// writePasswordRejected responds with 422 Unprocessable Entity like writeValidationError
+// if err is a password policy violation, and reports whether it did.
+func writePasswordRejected(w http.ResponseWriter, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	writeValidationError(w, validation.Errors{{Field: "password", Code: policyErr.Code, Message: policyErr.Message}})
+	return true
+}
+
+// writeHandleRejected responds with 422 Unprocessable Entity like writeValidationError
+// if err is a handle that is not allowed, and reports whether it did.
+func writeHandleRejected(w http.ResponseWriter, err error) bool {
+	var handleErr *handle.Error
+	if !errors.As(err, &handleErr) {
+		return false
+	}
+	writeValidationError(w, validation.Errors{{Field: "handle", Code: handleErr.Code, Message: handleErr.Message}})
+	return true
+}
+
+// writeLockout responds with 429 Too Many Requests if err is a lockout and reports whether it did.
+func writeLockout(w http.ResponseWriter, err error) bool {
+	var lockout *services.LockoutError
+	if !errors.As(err, &lockout) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(lockout.RetryAfter()))
+	writeError(w, http.StatusTooManyRequests, "locked_out", lockout.Error())
+	return true
+}
+
+// writeBlocked responds to a user reading the content of someone who has blocked them.
+func writeBlocked(w http.ResponseWriter) {
+	writeError(w, http.StatusForbidden, "blocked", "This user has blocked you")
+}
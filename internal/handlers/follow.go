@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"blog/internal/dto"
+	"blog/internal/middleware"
+	"blog/internal/models"
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type FollowHandler struct {
+	FollowService *services.FollowService
+}
+
+func NewFollowHandler(followService *services.FollowService) *FollowHandler {
+	return &FollowHandler{FollowService: followService}
+}
+
+// FollowUser handles the HTTP POST request to follow a user.
+//
+// It expects the ID of the user to follow as a path parameter and acts on behalf of the
+// authenticated user. If the user is followed, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID, or the user is the caller.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: One of the two users has blocked the other, with {"code": "blocked"}.
+// 404 Not Found: User not found.
+// 409 Conflict: The caller already follows the user.
+// 500 Internal Server Error: Failed to follow user.
+func (h *FollowHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	h.follow(w, r, h.FollowService.Follow)
+}
+
+// UnfollowUser handles the HTTP DELETE request to stop following a user.
+//
+// It expects the ID of the followed user as a path parameter. If the user is unfollowed,
+// it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID.
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: The caller does not follow the user.
+// 500 Internal Server Error: Failed to unfollow user.
+func (h *FollowHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	h.follow(w, r, h.FollowService.Unfollow)
+}
+
+// ListFollowers handles the HTTP GET request to list the users following a user.
+//
+// It expects a user ID as a path parameter and accepts the optional query parameters "limit"
+// (default 50, at most 200) and "offset". It returns a JSON array of public profiles,
+// most recent follower first. A token is optional.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID, limit or offset.
+// 401 Unauthorized: Invalid token.
+// 403 Forbidden: The user has blocked the caller, with {"code": "blocked"}.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to list followers.
+func (h *FollowHandler) ListFollowers(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.FollowService.ListFollowers)
+}
+
+// ListFollowing handles the HTTP GET request to list the users a user follows.
+//
+// It accepts the same parameters and responds like ListFollowers.
+func (h *FollowHandler) ListFollowing(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, h.FollowService.ListFollowing)
+}
+
+// follow runs action for the authenticated user and the user in the path.
+func (h *FollowHandler) follow(w http.ResponseWriter, r *http.Request, action func(followerID, followeeID uint) error) {
+	followeeID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = action(userID, uint(followeeID))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrSelfFollow:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrUNF:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case services.ErrNotFollowing:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrAlreadyFollowing:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrBlocked:
+		writeError(w, http.StatusForbidden, "blocked", "You cannot follow this user")
+	default:
+		http.Error(w, "Failed to update follow", http.StatusInternalServerError)
+	}
+}
+
+func (h *FollowHandler) list(w http.ResponseWriter, r *http.Request, list func(userID, viewerID uint, limit, offset int) ([]models.User, error)) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	limit, offset, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Anonymous callers have no ID, and nobody can block them
+	viewerID, _ := middleware.UserIDFromContext(r.Context())
+	users, err := list(uint(userID), viewerID, limit, offset)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewUsers(users))
+	case services.ErrUNF:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case services.ErrBlocked:
+		writeBlocked(w)
+	default:
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+	}
+}
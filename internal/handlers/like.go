@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"blog/internal/dto"
+	"blog/internal/middleware"
 	"blog/internal/services"
 	"encoding/json"
 	"net/http"
@@ -19,56 +21,64 @@ func NewLikeHandler(likeService *services.LikeService) *LikeHandler {
 
 // AddLikeHandler handles the HTTP POST request to add a like to a post.
 //
-// It expects post ID as a path parameter and user ID as a header parameter.
+// It expects post ID as a path parameter and acts on behalf of the authenticated user.
 // If the like is added successfully, it returns a 201 Created response.
 // Otherwise, it returns one of the following errors:
-// 400 Bad Request: Invalid post ID or user ID.
+// 400 Bad Request: Invalid post ID.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: The author of the post has blocked the caller, with {"code": "blocked"}.
+// 404 Not Found: Post not found.
 // 500 Internal Server Error: Failed to add like.
 func (h *LikeHandler) AddLikeHandler(w http.ResponseWriter, r *http.Request) {
 	postIDStr := mux.Vars(r)["postID"]
-	userIDStr := r.Header.Get("UserID")
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil {
 		http.Error(w, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.LikeService.AddLike(uint(postID), uint(userID)); err != nil {
+	err = h.LikeService.AddLike(uint(postID), userID, clientInfo(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+	case services.ErrPostNotFound:
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case services.ErrBlocked:
+		writeBlocked(w)
+	default:
 		http.Error(w, "Failed to add like", http.StatusInternalServerError)
-		return
 	}
-	w.WriteHeader(http.StatusCreated)
 }
 
 // RemoveLikeHandler handles the HTTP DELETE request to remove a like from a post.
 //
-// It expects post ID as a path parameter and user ID as a header parameter.
+// It expects post ID as a path parameter and acts on behalf of the authenticated user.
 // If the like is removed successfully, it returns a 204 No Content response.
 // Otherwise, it returns one of the following errors:
-// 400 Bad Request: Invalid post ID or user ID.
+// 400 Bad Request: Invalid post ID.
+// 401 Unauthorized: Missing or invalid token.
 // 500 Internal Server Error: Failed to remove like.
 func (h *LikeHandler) RemoveLikeHandler(w http.ResponseWriter, r *http.Request) {
 	postIDStr := mux.Vars(r)["postID"]
-	userIDStr := r.Header.Get("UserID")
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil {
 		http.Error(w, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.LikeService.RemoveLike(uint(postID), uint(userID)); err != nil {
+	if err := h.LikeService.RemoveLike(uint(postID), userID, clientInfo(r)); err != nil {
 		http.Error(w, "Failed to remove like", http.StatusInternalServerError)
 		return
 	}
@@ -78,8 +88,8 @@ func (h *LikeHandler) RemoveLikeHandler(w http.ResponseWriter, r *http.Request)
 // GetLikesCounterHandler handles the HTTP GET request to retrieve the number of likes for a post.
 //
 // It expects post ID as a path parameter.
-// If the likes count is retrieved successfully, it returns a JSON response with a single key-value pair,
-// where the key is "likes" and the value is the number of likes.
+// If the likes count is retrieved successfully, it returns a JSON response with
+// the "post_id" and the number of "likes".
 // Otherwise, it returns one of the following errors:
 // 400 Bad Request: Invalid post ID.
 // 500 Internal Server Error: Failed to get likes count.
@@ -97,5 +107,5 @@ func (h *LikeHandler) GetLikesCounterHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]int64{"likes": count})
+	json.NewEncoder(w).Encode(dto.LikeCount{PostID: uint(postID), Likes: count})
 }
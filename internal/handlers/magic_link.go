@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+)
+
+type MagicLinkHandler struct {
+	MagicLinkService *services.MagicLinkService
+}
+
+func NewMagicLinkHandler(magicLinkService *services.MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{MagicLinkService: magicLinkService}
+}
+
+// RequestMagicLink handles the HTTP POST request to log in without a password.
+//
+// It expects a JSON parameter "email". A single-use login link that expires in 15 minutes is
+// emailed if the address belongs to an account; the response is a 202 Accepted either way,
+// so it does not reveal whether the email is registered.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 422 Unprocessable Entity: Missing or invalid email.
+// 500 Internal Server Error: Failed to request login link.
+func (h *MagicLinkHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var linkReq struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+	if !decodeJSON(w, r, &linkReq) {
+		return
+	}
+
+	if err := h.MagicLinkService.RequestLink(linkReq.Email); err != nil {
+		http.Error(w, "Failed to request login link", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmMagicLink handles the HTTP GET request from an emailed login link.
+//
+// It expects a "token" query parameter and returns an HTML page that asks the user to confirm
+// the login, which POSTs the token to VerifyMagicLink. Opening the link does not use it up.
+func (h *MagicLinkHandler) ConfirmMagicLink(w http.ResponseWriter, r *http.Request) {
+	writeConfirmPage(w, r, "Log in", "Log in")
+}
+
+// VerifyMagicLink handles the HTTP POST request to log in with a login link.
+//
+// It expects a form parameter "token" from the link. If the link is valid, it returns the same
+// JSON response as /login. The link is only used up by a login that succeeds.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Invalid, used or expired link.
+// 403 Forbidden: Email not verified yet, with {"code": "email_not_verified"},
+// or account banned, with {"code": "account_banned"}.
+// 500 Internal Server Error: Failed to issue tokens.
+func (h *MagicLinkHandler) VerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	result, err := h.MagicLinkService.Verify(formToken(w, r), clientInfo(r))
+	switch err {
+	case nil:
+	case services.ErrInvalidMagicLink:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case services.ErrEmailNotVerified:
+		writeError(w, http.StatusForbidden, "email_not_verified", "Email address has not been verified")
+		return
+	case services.ErrUserBanned:
+		writeError(w, http.StatusForbidden, "account_banned", "Account is banned")
+		return
+	default:
+		http.Error(w, "Failed to login", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
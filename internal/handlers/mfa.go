@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"blog/internal/middleware"
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+)
+
+type MFAHandler struct {
+	MFAService *services.MFAService
+}
+
+func NewMFAHandler(mfaService *services.MFAService) *MFAHandler {
+	return &MFAHandler{MFAService: mfaService}
+}
+
+type mfaCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// LoginMFA handles the HTTP POST request for the second step of a login.
+//
+// It expects a JSON body with "mfa_token" from /login and either a TOTP "code"
+// or a "recovery_code". If the code is valid, it returns a token pair in the same format as /login.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 401 Unauthorized: Invalid or expired MFA token, or invalid code.
+// 403 Forbidden: Account banned, with {"code": "account_banned"}.
+// 422 Unprocessable Entity: Missing MFA token.
+// 429 Too Many Requests: Too many failed attempts, see Retry-After.
+// 500 Internal Server Error: Failed to complete login.
+func (h *MFAHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var loginReq struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+		mfaCodeRequest
+	}
+	if !decodeJSON(w, r, &loginReq) {
+		return
+	}
+
+	tokens, err := h.MFAService.CompleteLogin(loginReq.MFAToken, loginReq.Code, loginReq.RecoveryCode, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrInvalidToken, services.ErrInvalidMFACode, services.ErrMFANotEnabled, services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case services.ErrUserBanned:
+		writeError(w, http.StatusForbidden, "account_banned", "Account is banned")
+		return
+	default:
+		http.Error(w, "Failed to complete login", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// EnrollTOTP handles the HTTP POST request to start setting up TOTP two-factor authentication.
+//
+// It returns a JSON response with the base32 "secret" and an otpauth:// "provisioning_uri"
+// for authenticator apps. The setup is completed with /me/mfa/totp/confirm.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 409 Conflict: Two-factor authentication is already enabled.
+// 500 Internal Server Error: Failed to start enrollment.
+func (h *MFAHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.MFAService.BeginTOTPEnrollment(userID)
+	switch err {
+	case nil:
+	case services.ErrMFAAlreadyEnabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "Failed to start enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// ConfirmTOTP handles the HTTP POST request to finish setting up TOTP two-factor authentication.
+//
+// It expects a JSON parameter "code" from the authenticator app. If the code is valid, two-factor
+// authentication is enabled and it returns a JSON response with "recovery_codes", shown only once.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, invalid code or enrollment not started.
+// 401 Unauthorized: Missing or invalid token.
+// 409 Conflict: Two-factor authentication is already enabled.
+// 429 Too Many Requests: Too many failed attempts for the account or IP address, see Retry-After.
+// 500 Internal Server Error: Failed to enable two-factor authentication.
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var confirmReq mfaCodeRequest
+	if !decodeJSON(w, r, &confirmReq) {
+		return
+	}
+
+	codes, err := h.MFAService.ConfirmTOTPEnrollment(userID, confirmReq.Code, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrInvalidMFACode, services.ErrMFANotEnrolling:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrMFAAlreadyEnabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// DisableTOTP handles the HTTP DELETE request to turn off two-factor authentication.
+//
+// It expects a JSON body with either a TOTP "code" or a "recovery_code".
+// If two-factor authentication is disabled, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, invalid code or two-factor authentication not enabled.
+// 401 Unauthorized: Missing or invalid token.
+// 429 Too Many Requests: Too many failed attempts, see Retry-After.
+// 500 Internal Server Error: Failed to disable two-factor authentication.
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var disableReq mfaCodeRequest
+	if !decodeJSON(w, r, &disableReq) {
+		return
+	}
+
+	err := h.MFAService.DisableTOTP(userID, disableReq.Code, disableReq.RecoveryCode, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrInvalidMFACode, services.ErrMFANotEnabled:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+	}
+}
+
+// RegenerateRecoveryCodes handles the HTTP POST request to replace all recovery codes.
+//
+// It expects a JSON parameter "code" with a current TOTP code. If it is valid, it returns
+// a JSON response with the new "recovery_codes"; the previous ones stop working.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, invalid code or two-factor authentication not enabled.
+// 401 Unauthorized: Missing or invalid token.
+// 429 Too Many Requests: Too many failed attempts, see Retry-After.
+// 500 Internal Server Error: Failed to regenerate recovery codes.
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var regenerateReq mfaCodeRequest
+	if !decodeJSON(w, r, &regenerateReq) {
+		return
+	}
+
+	codes, err := h.MFAService.RegenerateRecoveryCodes(userID, regenerateReq.Code, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrInvalidMFACode, services.ErrMFANotEnabled:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
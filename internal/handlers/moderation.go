@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"blog/internal/middleware"
+	"blog/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type ModerationHandler struct {
+	ModerationService *services.ModerationService
+}
+
+func NewModerationHandler(moderationService *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{ModerationService: moderationService}
+}
+
+// BanUser handles the HTTP POST request to ban a user.
+//
+// It expects a user ID as a path parameter. Banned users cannot log in and their
+// existing tokens stop working. If the user is banned, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID.
+// 403 Forbidden: Missing permission, or the user's role is not below the caller's.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to ban user.
+func (h *ModerationHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, h.ModerationService.BanUser)
+}
+
+// UnbanUser handles the HTTP DELETE request to lift a ban.
+//
+// It expects a user ID as a path parameter and responds like BanUser.
+func (h *ModerationHandler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, h.ModerationService.UnbanUser)
+}
+
+// SetRole handles the HTTP PUT request to change a user's role.
+//
+// It expects a user ID as a path parameter and a JSON parameter "role"
+// ("user", "moderator" or "admin"). If the role is changed, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID, request or role.
+// 403 Forbidden: Missing permission, or the user's role is not below the caller's.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to change role.
+func (h *ModerationHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	var roleReq struct {
+		Role string `json:"role"`
+	}
+	if !decodeJSON(w, r, &roleReq) {
+		return
+	}
+
+	h.moderate(w, r, func(actor *services.Principal, userID uint, client services.ClientInfo) error {
+		return h.ModerationService.SetRole(actor, userID, roleReq.Role, client)
+	})
+}
+
+// UnlockUser handles the HTTP POST request to lift the failed-attempt lockouts of a user.
+//
+// It expects a user ID as a path parameter. If the lockouts are lifted,
+// it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID.
+// 403 Forbidden: Missing permission.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to unlock user.
+func (h *ModerationHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, h.ModerationService.UnlockUser)
+}
+
+// moderate runs action for the user in the path on behalf of the authenticated principal
+// and maps its result to a response.
+func (h *ModerationHandler) moderate(w http.ResponseWriter, r *http.Request, action func(*services.Principal, uint, services.ClientInfo) error) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = action(principal, uint(userID), clientInfo(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrInvalidRole:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrForbidden:
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case services.ErrUNF:
+		http.Error(w, "User not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+	}
+}
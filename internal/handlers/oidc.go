@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"blog/internal/services"
+	"blog/pkg/oidc"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// oidcStateCookie holds the state of the login started in this browser, so a callback URL
+// only completes the login in the browser that started it.
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	OIDCService *services.OIDCService
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{OIDCService: oidcService}
+}
+
+// Login handles the HTTP GET request to log in with an external identity provider.
+//
+// It expects the provider name as a path parameter and redirects the user to the provider
+// with a 302 Found response, setting a cookie the callback requires.
+// Otherwise, it returns one of the following errors:
+// 404 Not Found: Unknown provider.
+// 502 Bad Gateway: The provider could not be reached.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.OIDCService.AuthURL(mux.Vars(r)["provider"])
+	switch err {
+	case nil:
+		setOIDCStateCookie(w, r, state)
+		http.Redirect(w, r, authURL, http.StatusFound)
+	case services.ErrUnknownProvider:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("OIDC login failed, %s", err)
+		http.Error(w, "Failed to reach identity provider", http.StatusBadGateway)
+	}
+}
+
+// Callback handles the HTTP GET request the identity provider redirects back to.
+//
+// It expects "state" and "code" query parameters and the cookie set by Login. If the login
+// is successful, it returns the same JSON response as /login.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Missing parameters, invalid state, a login started in another browser
+// or the provider reported an error.
+// 401 Unauthorized: The ID token could not be verified.
+// 403 Forbidden: The provider did not verify the email address, or the account is banned.
+// 404 Not Found: Unknown provider.
+// 502 Bad Gateway: The code could not be exchanged with the provider.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "Identity provider returned "+providerErr, http.StatusBadRequest)
+		return
+	}
+	state, code := query.Get("state"), query.Get("code")
+	if state == "" || code == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var browserState string
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+	clearOIDCStateCookie(w, r)
+
+	result, err := h.OIDCService.Callback(mux.Vars(r)["provider"], state, browserState, code, clientInfo(r))
+	switch err {
+	case nil:
+	case services.ErrUnknownProvider:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case services.ErrInvalidOIDCState:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case oidc.ErrInvalidIDToken:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case services.ErrOIDCEmailNotVerified:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case services.ErrUserBanned:
+		writeError(w, http.StatusForbidden, "account_banned", "Account is banned")
+		return
+	default:
+		log.Printf("OIDC callback failed, %s", err)
+		http.Error(w, "Failed to complete login with identity provider", http.StatusBadGateway)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// setOIDCStateCookie keeps state in the browser for the callback. SameSite=Lax still sends it
+// on the provider's top-level redirect back to the callback.
+func setOIDCStateCookie(w http.ResponseWriter, r *http.Request, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   int(services.OIDCLoginLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearOIDCStateCookie deletes the cookie once a callback has used it.
+func clearOIDCStateCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/auth/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+var errInvalidPage = errors.New("invalid limit or offset")
+
+// parsePage reads the optional "limit" and "offset" query parameters. Missing ones are zero,
+// which the services replace with their defaults.
+func parsePage(query url.Values) (int, int, error) {
+	var page [2]int
+	for i, name := range []string{"limit", "offset"} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return 0, 0, errInvalidPage
+			}
+			page[i] = n
+		}
+	}
+	return page[0], page[1], nil
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"blog/internal/models"
+	"blog/internal/dto"
+	"blog/internal/middleware"
 	"blog/internal/services"
 	"encoding/json"
 	"net/http"
@@ -20,27 +21,45 @@ func NewPostHandler(postService *services.PostService) *PostHandler {
 
 // CreatePostHandler handles the HTTP POST request to create a new post.
 //
-// It expects a JSON body with the post details. If the post is created successfully,
-// it returns a 201 Created response with the created post in JSON format.
+// It expects two JSON parameters: "title" and "content"; any other field is rejected.
+// The post is always created on behalf of the authenticated user. If the post is created
+// successfully, it returns a 201 Created response with the created post in JSON format.
 // Otherwise, it returns one of the following errors:
 // 400 Bad Request: If the request body is invalid.
-// 500 Internal Server Error: If the server fails to create the post.
+// 401 Unauthorized: Missing or invalid token.
+// 413 Request Entity Too Large: Request body too large.
+// 422 Unprocessable Entity: Missing or too long title, or missing content, with the list of "errors".
+// 500 Internal Server Error: Failed to create post.
 func (h *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
-	var post models.Post
-	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.PostService.CreatePost(&post); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var createReq services.CreatePostInput
+	if !decodeJSON(w, r, &createReq) {
+		return
+	}
+
+	post, err := h.PostService.CreatePost(userID, createReq, clientInfo(r))
+	if err != nil {
+		http.Error(w, "Failed to create post", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(post)
+	json.NewEncoder(w).Encode(dto.NewPost(post))
 }
 
+// GetPostsByUserIDHandler handles the HTTP GET request to list the posts of a user.
+//
+// It expects a user ID as a path parameter and returns a JSON array of posts. A token is optional.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID.
+// 401 Unauthorized: Invalid token.
+// 403 Forbidden: The user has blocked the caller, with {"code": "blocked"}.
+// 500 Internal Server Error: Failed to retrieve posts.
 func (h *PostHandler) GetPostsByUserIDHandler(w http.ResponseWriter, r *http.Request) {
 	userIDStr := mux.Vars(r)["userID"]
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -49,23 +68,173 @@ func (h *PostHandler) GetPostsByUserIDHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	posts, err := h.PostService.GetPostsByUserID(uint(userID))
-	if err != nil {
+	viewerID, _ := middleware.UserIDFromContext(r.Context())
+	posts, err := h.PostService.GetPostsByUserID(uint(userID), viewerID)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewPosts(posts))
+	case services.ErrBlocked:
+		writeBlocked(w)
+	default:
 		http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
+	}
+}
+
+// GetFeedHandler handles the HTTP GET request to read the caller's feed.
+//
+// It accepts the optional query parameters "limit" (default 50, at most 200) and "offset",
+// and returns a JSON array of the newest posts by the authors the caller follows,
+// leaving out muted authors.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid limit or offset.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: API token without the posts:read scope.
+// 500 Internal Server Error: Failed to retrieve feed.
+func (h *PostHandler) GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	json.NewEncoder(w).Encode(posts)
+	posts, err := h.PostService.GetFeed(userID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve feed", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewPosts(posts))
+}
+
+// GetMentionedUsersHandler handles the HTTP GET request to list the users a post mentions.
+//
+// It expects a post ID as a path parameter and returns a JSON array of the public profiles
+// of the users mentioned as @handle in the post content. A token is optional.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid post ID.
+// 401 Unauthorized: Invalid token.
+// 403 Forbidden: The author has blocked the caller, with {"code": "blocked"}.
+// 404 Not Found: Post not found.
+// 500 Internal Server Error: Failed to retrieve mentions.
+func (h *PostHandler) GetMentionedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseUint(mux.Vars(r)["postID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	viewerID, _ := middleware.UserIDFromContext(r.Context())
+	users, err := h.PostService.GetMentionedUsers(uint(postID), viewerID)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewUsers(users))
+	case services.ErrPostNotFound:
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case services.ErrBlocked:
+		writeBlocked(w)
+	default:
+		http.Error(w, "Failed to retrieve mentions", http.StatusInternalServerError)
+	}
+}
+
+// GetMentionsHandler handles the HTTP GET request to list the posts that mention the caller.
+//
+// It accepts the optional query parameters "limit" (default 50, at most 200) and "offset",
+// and returns a JSON array of posts, newest first, leaving out muted and blocked authors.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid limit or offset.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: API token without the posts:read scope.
+// 500 Internal Server Error: Failed to retrieve mentions.
+func (h *PostHandler) GetMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, offset, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := h.PostService.GetMentions(userID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve mentions", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewPosts(posts))
+}
+
+// UpdatePostHandler handles the HTTP PUT request to replace a post and the HTTP PATCH request
+// to change part of it.
+//
+// It expects a post ID as a path parameter and a JSON request body with "title" and "content";
+// both are required for PUT, and fields left out of a PATCH are not changed. Authors may edit
+// their own posts; moderators and admins may edit any post. Users mentioned in the new content
+// replace those of the old content. If the post is updated, it returns the updated post in JSON format.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid post ID or request body.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: The post belongs to someone else.
+// 404 Not Found: Post not found.
+// 413 Request Entity Too Large: Request body too large.
+// 422 Unprocessable Entity: Missing or too long title, or missing content, with the list of "errors".
+// 500 Internal Server Error: Failed to update post.
+func (h *PostHandler) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseUint(mux.Vars(r)["postID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update services.PostUpdate
+	if r.Method == http.MethodPut {
+		var replaceReq services.CreatePostInput
+		if !decodeJSON(w, r, &replaceReq) {
+			return
+		}
+		update = services.PostUpdate{Title: &replaceReq.Title, Content: &replaceReq.Content}
+	} else if !decodeJSON(w, r, &update) {
+		return
+	}
+
+	post, err := h.PostService.UpdatePost(uint(postID), principal, update, clientInfo(r))
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewPost(post))
+	case services.ErrPostNotFound:
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case services.ErrForbidden:
+		http.Error(w, "You can only edit your own posts", http.StatusForbidden)
+	default:
+		http.Error(w, "Failed to update post", http.StatusInternalServerError)
+	}
 }
 
 // DeletePostHandler handles the HTTP DELETE request to delete a post.
 //
-// It expects a post ID as a path parameter.
+// It expects a post ID as a path parameter. Authors may delete their own posts;
+// moderators and admins may delete any post.
 // If the post is deleted successfully, it returns a 204 No Content response.
 // Otherwise, it returns one of the following errors:
 // 400 Bad Request: Invalid post ID.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: The post belongs to someone else.
+// 404 Not Found: Post not found.
 // 500 Internal Server Error: Failed to delete post.
-
 func (h *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request) {
 	postIDStr := mux.Vars(r)["postID"]
 	postID, err := strconv.ParseUint(postIDStr, 10, 32)
@@ -74,9 +243,21 @@ func (h *PostHandler) DeletePostHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.PostService.DeletePost(uint(postID)); err != nil {
-		http.Error(w, "Failed to delete post", http.StatusInternalServerError)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+
+	err = h.PostService.DeletePost(uint(postID), principal, clientInfo(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrPostNotFound:
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case services.ErrForbidden:
+		http.Error(w, "You can only delete your own posts", http.StatusForbidden)
+	default:
+		http.Error(w, "Failed to delete post", http.StatusInternalServerError)
+	}
 }
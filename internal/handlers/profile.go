@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"blog/internal/dto"
+	"blog/internal/middleware"
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type ProfileHandler struct {
+	ProfileService *services.ProfileService
+}
+
+func NewProfileHandler(profileService *services.ProfileService) *ProfileHandler {
+	return &ProfileHandler{ProfileService: profileService}
+}
+
+// GetUser handles the HTTP GET request to retrieve a user's public profile.
+//
+// It expects a user ID as a path parameter. If the user exists, it returns a JSON
+// response with the public profile, which never includes the email address,
+// and the "followers_count" and "following_count".
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid user ID.
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to retrieve profile.
+func (h *ProfileHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.ProfileService.GetProfile(uint(userID))
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewProfile(profile.User, profile.FollowersCount, profile.FollowingCount))
+	case services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to retrieve profile", http.StatusInternalServerError)
+	}
+}
+
+// GetUserByHandle handles the HTTP GET request to retrieve a user's public profile by handle.
+//
+// It expects a handle as a path parameter, matched case-insensitively, and returns the same
+// JSON response as GetUser. If the handle was recently given up by a user, it returns a
+// 301 Moved Permanently response pointing to the profile under the user's current handle.
+// Otherwise, it returns one of the following errors:
+// 404 Not Found: User not found.
+// 500 Internal Server Error: Failed to retrieve profile.
+func (h *ProfileHandler) GetUserByHandle(w http.ResponseWriter, r *http.Request) {
+	profile, redirectTo, err := h.ProfileService.GetProfileByHandle(mux.Vars(r)["handle"])
+	switch {
+	case err == nil && redirectTo != "":
+		http.Redirect(w, r, "/users/@"+redirectTo, http.StatusMovedPermanently)
+	case err == nil:
+		json.NewEncoder(w).Encode(dto.NewProfile(profile.User, profile.FollowersCount, profile.FollowingCount))
+	case err == services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to retrieve profile", http.StatusInternalServerError)
+	}
+}
+
+// GetMe handles the HTTP GET request to retrieve the caller's own profile.
+//
+// It returns a JSON response with the public profile fields plus "email",
+// "is_verified", "role", "totp_enabled" and "updated_at".
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: User not found.
+func (h *ProfileHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.ProfileService.GetUser(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewMe(user))
+}
+
+// UpdateMe handles the HTTP PATCH request to update the caller's profile.
+//
+// It expects a JSON request body with any of "name", "display_name", "bio", "website"
+// and "avatar_url"; fields that are left out are not changed, and an empty "website" or
+// "avatar_url" clears it. If the profile is updated, it returns the updated profile like GetMe.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: User not found.
+// 422 Unprocessable Entity: Blank name, field too long or website or avatar URL not an http or https URL,
+// with the list of "errors".
+// 500 Internal Server Error: Failed to update profile.
+func (h *ProfileHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update services.ProfileUpdate
+	if !decodeJSON(w, r, &update) {
+		return
+	}
+
+	user, err := h.ProfileService.UpdateProfile(userID, update)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewMe(user))
+	case services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+	}
+}
+
+// SetHandle handles the HTTP PUT request to choose or change the caller's handle.
+//
+// It expects a JSON parameter "handle" of 3 to 30 letters, digits or underscores, with at least
+// one letter. Handles are case-insensitive and stored in lowercase. The previous handle keeps
+// redirecting to the caller for 90 days, and nobody else can take it in that time.
+// If the handle is set, it returns the updated profile like GetMe.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: User not found.
+// 409 Conflict: Handle already taken.
+// 422 Unprocessable Entity: Missing, invalid or reserved handle, with the list of "errors".
+// 500 Internal Server Error: Failed to set handle.
+func (h *ProfileHandler) SetHandle(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var handleReq struct {
+		Handle string `json:"handle" validate:"required,max=31"`
+	}
+	if !decodeJSON(w, r, &handleReq) {
+		return
+	}
+
+	user, err := h.ProfileService.SetHandle(userID, handleReq.Handle)
+	if writeHandleRejected(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(dto.NewMe(user))
+	case services.ErrUNF:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrHandleTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to set handle", http.StatusInternalServerError)
+	}
+}
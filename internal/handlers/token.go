@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"blog/internal/dto"
+	"blog/internal/middleware"
+	"blog/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type TokenHandler struct {
+	TokenService *services.TokenService
+}
+
+func NewTokenHandler(tokenService *services.TokenService) *TokenHandler {
+	return &TokenHandler{TokenService: tokenService}
+}
+
+// RefreshToken handles the HTTP POST request to exchange a refresh token for a new token pair.
+//
+// It expects a JSON body with a "refresh_token" parameter. The presented token is consumed;
+// the response contains a new "access_token" and "refresh_token" in the same format as /login.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 401 Unauthorized: Invalid, expired, revoked or already used refresh token.
+// 422 Unprocessable Entity: Missing refresh token.
+// 500 Internal Server Error: Failed to refresh token.
+func (h *TokenHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var refreshReq struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+	if !decodeJSON(w, r, &refreshReq) {
+		return
+	}
+
+	tokens, err := h.TokenService.Refresh(refreshReq.RefreshToken, clientInfo(r))
+	switch err {
+	case nil:
+	case services.ErrInvalidToken, services.ErrRefreshTokenReused:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	default:
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout handles the HTTP POST request to log out.
+//
+// It expects a JSON body with a "refresh_token" parameter and ends the session it was issued
+// for: every refresh token of the login is revoked and its access tokens stop working immediately.
+// If the logout is successful, it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 401 Unauthorized: Unknown refresh token.
+// 422 Unprocessable Entity: Missing refresh token.
+// 500 Internal Server Error: Failed to revoke tokens.
+func (h *TokenHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var logoutReq struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+	if !decodeJSON(w, r, &logoutReq) {
+		return
+	}
+
+	err := h.TokenService.Revoke(logoutReq.RefreshToken, clientInfo(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrInvalidToken:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		http.Error(w, "Failed to logout", http.StatusInternalServerError)
+	}
+}
+
+// JWKS handles the HTTP GET request for the public signing keys.
+//
+// It returns a JSON Web Key Set (RFC 7517) with every non-retired asymmetric key,
+// so other services can verify blog tokens without sharing secrets.
+func (h *TokenHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.TokenService.Keys.JWKS())
+}
+
+// CreateAPIToken handles the HTTP POST request to create a personal access token.
+//
+// It expects a JSON body with "name", a list of "scopes" (posts:read, posts:write,
+// likes:write, follows:write) and optionally "expires_in_days" (1-365, default 30).
+// Tokens can only be created within 10 minutes of logging in, so callers whose session is older
+// have to log in again first.
+// If the token is created, it returns a 201 Created response with the token details and
+// the "token" itself, which is shown only this once.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, name, scopes or lifetime.
+// 401 Unauthorized: Missing or invalid token.
+// 403 Forbidden: The session logged in too long ago, with {"code": "reauthentication_required"}.
+// 500 Internal Server Error: Failed to create token.
+func (h *TokenHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var createReq struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if !decodeJSON(w, r, &createReq) {
+		return
+	}
+
+	token, err := h.TokenService.CreateAPIToken(principal.UserID, principal.SessionID, createReq.Name, createReq.Scopes, createReq.ExpiresInDays, clientInfo(r))
+	switch err {
+	case nil:
+	case services.ErrReauthRequired:
+		writeError(w, http.StatusForbidden, "reauthentication_required", "Log in again to create a token")
+		return
+	case services.ErrInvalidTokenName, services.ErrInvalidScope, services.ErrInvalidTokenLifetime:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewCreatedAPIToken(&token.APIToken, token.Token))
+}
+
+// ListAPITokens handles the HTTP GET request to list the caller's personal access tokens.
+//
+// It returns a JSON array of the tokens that have not been revoked, without the secrets.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 500 Internal Server Error: Failed to list tokens.
+func (h *TokenHandler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.TokenService.ListAPITokens(userID)
+	if err != nil {
+		http.Error(w, "Failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewAPITokens(tokens))
+}
+
+// RevokeAPIToken handles the HTTP DELETE request to revoke a personal access token.
+//
+// It expects a token ID as a path parameter. If the token is revoked,
+// it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid token ID.
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: No such token among the caller's tokens.
+// 500 Internal Server Error: Failed to revoke token.
+func (h *TokenHandler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(mux.Vars(r)["tokenID"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.TokenService.RevokeAPIToken(userID, uint(tokenID), clientInfo(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrAPITokenNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+	}
+}
+
+// ListSessions handles the HTTP GET request to list the caller's active sessions.
+//
+// It returns a JSON array of the sessions, most recently used first. The session of the
+// request is marked with "current".
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 500 Internal Server Error: Failed to list sessions.
+func (h *TokenHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.TokenService.ListSessions(principal.UserID)
+	if err != nil {
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(dto.NewSessions(sessions, principal.SessionID))
+}
+
+// RevokeSession handles the HTTP DELETE request to sign out one of the caller's sessions.
+//
+// It expects a session ID as a path parameter. If the session is revoked, it returns a
+// 204 No Content response; its refresh and access tokens stop working immediately.
+// Otherwise, it returns one of the following errors:
+// 401 Unauthorized: Missing or invalid token.
+// 404 Not Found: No such active session among the caller's sessions.
+// 500 Internal Server Error: Failed to revoke session.
+func (h *TokenHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.TokenService.RevokeSession(userID, mux.Vars(r)["sessionID"])
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrSessionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+	}
+}
@@ -1,10 +1,8 @@
 package handlers
 
 import (
-	"blog/internal/models"
 	"blog/internal/services"
 	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
@@ -18,57 +16,87 @@ func NewUserHandler(userService *services.UserService) *UserHandler {
 
 // RegisterUser handles the HTTP POST request to register a new user.
 //
-// It expects a JSON request body that matches the models.User struct.
+// It expects three JSON parameters: "name", "email" and "password", and optionally a "handle";
+// any other field is rejected.
 // If the user is registered successfully, it returns a 201 Created response
 // with a JSON response body of the form {"message": "User registered successfully"}.
 // Otherwise, it returns one of the following errors:
 // 400 Bad Request: Invalid request.
+// 409 Conflict: Email or handle already exists.
+// 413 Request Entity Too Large: Request body too large.
+// 422 Unprocessable Entity: Invalid name, email, password or handle, with the list of "errors";
+// passwords must satisfy the password policy and must not be known from data breaches.
 // 500 Internal Server Error: Failed to register user.
 func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	var registerReq services.RegisterInput
+	if !decodeJSON(w, r, &registerReq) {
 		return
 	}
 
-	err := h.UserService.RegisterUser(&user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	err := h.UserService.RegisterUser(registerReq, clientInfo(r))
+	if writePasswordRejected(w, err) || writeHandleRejected(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrEmailTaken, services.ErrHandleTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "Failed to register user", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Println("User registered successfully")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully"})
 }
 
 // LoginUser handles the HTTP POST request to login a user.
 // It expects two JSON parameters: "email" and "password".
-// If the login is successful, it returns a JSON response with a single key-value pair,
-// where the key is "token" and the value is a JWT authentication token.
+// If the login is successful, it returns a JSON response with a short-lived
+// "access_token" JWT and an opaque "refresh_token" that can be exchanged at /token/refresh.
+// For users with two-factor authentication it instead returns {"mfa_required": true, "mfa_token": ...},
+// and the login is completed at /login/mfa.
 // Otherwise, it returns one of the following errors:
 // 400 Bad Request: Invalid request.
 // 401 Unauthorized: User not found or invalid credentials.
+// 422 Unprocessable Entity: Missing password, or missing or invalid email.
+// 403 Forbidden: Email not verified yet, with {"code": "email_not_verified"},
+// or account banned, with {"code": "account_banned"}.
+// 429 Too Many Requests: Too many failed attempts for the account or IP address, see Retry-After.
+// 500 Internal Server Error: Failed to issue tokens.
 func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var loginReq struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required,email,max=255"`
+		Password string `json:"password" validate:"required"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &loginReq) {
 		return
 	}
 
-	token, err := h.UserService.Login(loginReq.Email, loginReq.Password)
-	if err != nil {
+	result, err := h.UserService.Login(loginReq.Email, loginReq.Password, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrUNF, services.ErrInvalidCredentials:
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
+	case services.ErrEmailNotVerified:
+		writeError(w, http.StatusForbidden, "email_not_verified", "Email address has not been verified")
+		return
+	case services.ErrUserBanned:
+		writeError(w, http.StatusForbidden, "account_banned", "Account is banned")
+		return
+	default:
+		http.Error(w, "Failed to login", http.StatusInternalServerError)
+		return
 	}
 
-	resp := map[string]string{"token": token}
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(result)
 }
 
 // VerifyEmail handles the HTTP POST request to verify a user's email address.
@@ -76,20 +104,25 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 // It expects two JSON parameters: "email" and "token".
 // If the verification is successful, it returns a 200 OK response.
 // Otherwise, it returns one of the following errors:
-// 400 Bad Request: Invalid request.
-// 401 Unauthorized: User not found or invalid verification code.
+// 400 Bad Request: Invalid request, invalid or expired verification code.
+// 404 Not Found: User not found.
+// 422 Unprocessable Entity: Missing token, or missing or invalid email.
+// 429 Too Many Requests: Too many wrong codes; a new code must be requested via /verify/resend,
+// or too many failed attempts for the account or IP address, see Retry-After.
 // 500 Internal Server Error: Failed to verify email.
 func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 	var verifyReq struct {
-		Token string `json:"token"`
-		Email string `json:"email"`
+		Token string `json:"token" validate:"required"`
+		Email string `json:"email" validate:"required,email,max=255"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&verifyReq); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &verifyReq) {
 		return
 	}
 
-	err := h.UserService.VerifyEmail(verifyReq.Email, verifyReq.Token)
+	err := h.UserService.VerifyEmail(verifyReq.Email, verifyReq.Token, clientInfo(r))
+	if writeLockout(w, err) {
+		return
+	}
 	switch err {
 	case services.ErrUNF:
 		http.Error(w, "User not found", http.StatusNotFound)
@@ -97,10 +130,103 @@ func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 	case services.ErrIVC:
 		http.Error(w, "Invalid verification code", http.StatusBadRequest)
 		return
+	case services.ErrVerificationExpired:
+		http.Error(w, "Verification code expired", http.StatusBadRequest)
+		return
+	case services.ErrTooManyAttempts:
+		http.Error(w, "Too many verification attempts", http.StatusTooManyRequests)
+		return
 	case nil:
 		w.WriteHeader(http.StatusOK)
 	default:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ResendVerification handles the HTTP POST request to send a new verification code.
+//
+// It expects a JSON parameter "email". The previous code is replaced and the attempt counter reset.
+// It returns a 202 Accepted response whether or not the email belongs to an unverified account;
+// a code is sent at most once a minute.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 422 Unprocessable Entity: Missing or invalid email.
+// 429 Too Many Requests: Too many requests from this IP address, with a Retry-After header.
+// 500 Internal Server Error: Failed to create verification code.
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var resendReq struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+	if !decodeJSON(w, r, &resendReq) {
+		return
+	}
+
+	err := h.UserService.ResendVerification(resendReq.Email, clientIP(r))
+	if writeLockout(w, err) {
 		return
 	}
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "Failed to create verification code", http.StatusInternalServerError)
+	}
+}
+
+// RequestPasswordReset handles the HTTP POST request to start a password reset.
+//
+// It expects a JSON parameter "email". A reset code is emailed if the address belongs
+// to an account; the response is a 202 Accepted either way, so it does not reveal
+// whether the email is registered.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request.
+// 422 Unprocessable Entity: Missing or invalid email.
+// 500 Internal Server Error: Failed to request password reset.
+func (h *UserHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var resetReq struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+	if !decodeJSON(w, r, &resetReq) {
+		return
+	}
+
+	if err := h.UserService.RequestPasswordReset(resetReq.Email); err != nil {
+		http.Error(w, "Failed to request password reset", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmPasswordReset handles the HTTP POST request to finish a password reset.
+//
+// It expects two JSON parameters: "token" (the emailed reset code) and "password".
+// If the password is changed successfully, every existing session of the user is revoked
+// and it returns a 204 No Content response.
+// Otherwise, it returns one of the following errors:
+// 400 Bad Request: Invalid request, empty password or invalid, used or expired reset code.
+// 422 Unprocessable Entity: Missing token, or a password that is longer than 72 bytes
+// or does not satisfy the password policy.
+// 500 Internal Server Error: Failed to reset password.
+func (h *UserHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var confirmReq struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,maxbytes=72"`
+	}
+	if !decodeJSON(w, r, &confirmReq) {
+		return
+	}
+
+	err := h.UserService.ResetPassword(confirmReq.Token, confirmReq.Password, clientInfo(r))
+	if writePasswordRejected(w, err) {
+		return
+	}
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case services.ErrInvalidResetToken, services.ErrEmptyPassword:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+	}
 }
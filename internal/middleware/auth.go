@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"blog/internal/rbac"
+	"blog/internal/services"
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// Auth returns a middleware that authenticates requests using the
+// "Authorization: Bearer <token>" header.
+//
+// The token must be a valid access token or personal access token issued by TokenService
+// and belong to a user who is not banned. On success the authenticated principal is stored in the request context and can
+// be read with PrincipalFromContext or UserIDFromContext.
+// Otherwise, it returns 401 Unauthorized, or 403 Forbidden for banned users.
+func Auth(userService *services.UserService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authenticate(userService, w, r, next)
+		})
+	}
+}
+
+// OptionalAuth is like Auth for public endpoints that tailor the response to the caller:
+// requests without an Authorization header pass through anonymously, while a header
+// that is present must hold a valid token.
+func OptionalAuth(userService *services.UserService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authenticate(userService, w, r, next)
+		})
+	}
+}
+
+func authenticate(userService *services.UserService, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	header := r.Header.Get("Authorization")
+	tokenStr, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || tokenStr == "" {
+		http.Error(w, "Missing or malformed authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	principal, err := userService.Authenticate(tokenStr, ClientInfo(r))
+	switch err {
+	case nil:
+	case services.ErrUserBanned:
+		http.Error(w, "Account is banned", http.StatusForbidden)
+		return
+	default:
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), principalKey, principal)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequirePermission returns a middleware that only lets principals whose role grants
+// permission through. It must run after Auth; otherwise, it returns 403 Forbidden.
+func RequirePermission(permission rbac.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok || !principal.Can(permission) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession returns 403 Forbidden for requests authenticated with a personal access token,
+// for account management that must not be scriptable with a leaked token. It must run after Auth.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok || principal.APITokenID != 0 {
+			http.Error(w, "This endpoint cannot be used with an API token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PrincipalFromContext returns the authenticated principal stored by Auth.
+func PrincipalFromContext(ctx context.Context) (*services.Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*services.Principal)
+	return principal, ok
+}
+
+// UserIDFromContext returns the ID of the authenticated user stored by Auth.
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	return principal.UserID, true
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"blog/internal/models"
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"blog/internal/services"
+	"blog/internal/testutil"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPITokensAreLimitedToTheirScopes(t *testing.T) {
+	db := testutil.OpenDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	auditService := services.NewAuditService(repositories.NewAuditRepository(db))
+	tokenService := services.NewTokenService(repositories.NewTokenRepository(db), testutil.Keyring(t), auditService)
+	throttleService := services.NewThrottleService(repositories.NewThrottleRepository(db))
+	userService := services.NewUserService(userRepo, tokenService, throttleService, nil, nil, auditService)
+
+	client := services.ClientInfo{IP: "198.51.100.1"}
+	// credential signs the user in and returns an access token, or an API token with the scopes
+	credential := func(t *testing.T, email, role string, scopes []string) string {
+		t.Helper()
+		user := &models.User{Name: "Test User", Email: email, Password: "unusable", IsVerified: true, Role: role}
+		if err := userRepo.Create(user); err != nil {
+			t.Fatal(err)
+		}
+		pair, err := tokenService.Issue(user.ID, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scopes == nil {
+			return pair.AccessToken
+		}
+		_, sessionID, err := tokenService.ParseAccessToken(pair.AccessToken, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiToken, err := tokenService.CreateAPIToken(user.ID, sessionID, "test", scopes, 0, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return apiToken.Token
+	}
+
+	allScopes := []string{"posts:read", "posts:write", "likes:write", "follows:write"}
+	tests := []struct {
+		name   string
+		role   string
+		scopes []string
+		guard  func(http.Handler) http.Handler
+		want   int
+	}{
+		{"session creating a post", rbac.RoleUser, nil, RequirePermission(rbac.PostCreate), http.StatusOK},
+		{"token with the scope creating a post", rbac.RoleUser, []string{"posts:write"}, RequirePermission(rbac.PostCreate), http.StatusOK},
+		{"token without the scope creating a post", rbac.RoleUser, []string{"posts:read"}, RequirePermission(rbac.PostCreate), http.StatusForbidden},
+		{"write token reading posts", rbac.RoleUser, []string{"posts:write"}, RequirePermission(rbac.PostRead), http.StatusForbidden},
+		{"token without the scope following", rbac.RoleUser, []string{"posts:read", "posts:write", "likes:write"}, RequirePermission(rbac.FollowWrite), http.StatusForbidden},
+		{"admin session banning", rbac.RoleAdmin, nil, RequirePermission(rbac.UserBan), http.StatusOK},
+		{"admin token banning, which no scope allows", rbac.RoleAdmin, allScopes, RequirePermission(rbac.UserBan), http.StatusForbidden},
+		{"scope beyond the role", rbac.RoleUser, allScopes, RequirePermission(rbac.PostDeleteAny), http.StatusForbidden},
+		{"session managing the account", rbac.RoleModerator, nil, RequireSession, http.StatusOK},
+		{"token managing the account", rbac.RoleModerator, allScopes, RequireSession, http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := credential(t, fmt.Sprintf("user%d@example.com", i), tt.role, tt.scopes)
+			ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+			handler := Auth(userService)(tt.guard(ok))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
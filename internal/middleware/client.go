@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"blog/internal/services"
+)
+
+// ClientInfo returns the address and user agent of the directly connected client. Forwarding
+// headers are ignored on purpose: they are set by the client unless a trusted proxy rewrites them.
+func ClientInfo(r *http.Request) services.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return services.ClientInfo{IP: ip, UserAgent: r.UserAgent()}
+}
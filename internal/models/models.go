@@ -7,24 +7,39 @@ import (
 )
 
 type User struct {
-	ID               uint           `gorm:"primaryKey" json:"id"`
-	Name             string         `gorm:"size:100;not null" json:"name"`
-	Email            string         `gorm:"size:255;unique;not null" json:"email"`
-	Password         string         `gorm:"size:255;not null" json:"password"`
-	IsVerified       bool           `gorm:"default:false" json:"is_verified"`
-	VerificationCode string         `gorm:"size:255" json:"verification_code"`
-	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt        time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	ID                    uint    `gorm:"primaryKey"`
+	Name                  string  `gorm:"size:100;not null"`
+	Handle                *string `gorm:"size:30;uniqueIndex"`
+	DisplayName           string  `gorm:"size:100"`
+	Bio                   string  `gorm:"size:500"`
+	Website               string  `gorm:"size:255"`
+	AvatarURL             string  `gorm:"size:255"`
+	Email                 string  `gorm:"size:255;unique;not null"`
+	PendingEmail          string  `gorm:"size:255"`
+	Password              string  `gorm:"size:255;not null"`
+	IsVerified            bool    `gorm:"default:false"`
+	Role                  string  `gorm:"size:20;not null;default:user"`
+	BannedAt              *time.Time
+	VerificationCode      string `gorm:"size:255"`
+	VerificationExpiresAt *time.Time
+	VerificationSentAt    *time.Time
+	VerificationAttempts  int            `gorm:"not null;default:0"`
+	TOTPSecret            string         `gorm:"size:64"`
+	TOTPEnabled           bool           `gorm:"not null;default:false"`
+	TOTPLastStep          int64          `gorm:"not null;default:0"`
+	DeletionScheduledAt   *time.Time     `gorm:"index"`
+	CreatedAt             time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt             time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt             gorm.DeletedAt `gorm:"index"`
 }
 
 type Post struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	UserID    uint           `gorm:"not null;index" json:"user_id"`
-	Title     string         `gorm:"size:255;not null" json:"title"`
-	Content   string         `gorm:"type:text;not null" json:"content"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	ID        uint           `gorm:"primaryKey"`
+	UserID    uint           `gorm:"not null;index"`
+	Title     string         `gorm:"size:255;not null"`
+	Content   string         `gorm:"type:text;not null"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
@@ -34,3 +49,213 @@ type Like struct {
 	PostID    uint      `gorm:"not null;index"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
+
+// HandleRedirect keeps a user's previous handle pointing at them for a while after a rename.
+// Until ExpiresAt nobody else can claim the handle.
+type HandleRedirect struct {
+	ID        uint      `gorm:"primaryKey"`
+	Handle    string    `gorm:"size:30;not null;uniqueIndex"`
+	UserID    uint      `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Mention records that the content of PostID mentions UserID as @handle.
+type Mention struct {
+	ID        uint      `gorm:"primaryKey"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_mention_pair"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_mention_pair;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Follow means FollowerID follows FolloweeID. A user can follow another user once,
+// and never themselves.
+type Follow struct {
+	ID         uint      `gorm:"primaryKey"`
+	FollowerID uint      `gorm:"not null;uniqueIndex:idx_follow_pair;check:chk_follow_not_self,follower_id <> followee_id"`
+	FolloweeID uint      `gorm:"not null;uniqueIndex:idx_follow_pair;index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// Block means BlockerID has blocked BlockedID, who may then no longer interact with
+// the blocker or their content.
+type Block struct {
+	ID        uint      `gorm:"primaryKey"`
+	BlockerID uint      `gorm:"not null;uniqueIndex:idx_block_pair;check:chk_block_not_self,blocker_id <> blocked_id"`
+	BlockedID uint      `gorm:"not null;uniqueIndex:idx_block_pair;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Mute means MuterID no longer sees MutedID's posts in their feed. The muted user is not told.
+type Mute struct {
+	ID        uint      `gorm:"primaryKey"`
+	MuterID   uint      `gorm:"not null;uniqueIndex:idx_mute_pair;check:chk_mute_not_self,muter_id <> muted_id"`
+	MutedID   uint      `gorm:"not null;uniqueIndex:idx_mute_pair;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Session is one login of a user on a device. Its ID is shared by the refresh tokens
+// rotated from that login and is embedded in every access token issued for it.
+type Session struct {
+	ID         string    `gorm:"primaryKey;size:64"`
+	UserID     uint      `gorm:"not null;index"`
+	UserAgent  string    `gorm:"size:255"`
+	IP         string    `gorm:"size:45"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	LastSeenAt time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+}
+
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	FamilyID  string    `gorm:"size:64;not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// MagicLinkToken records a passwordless login link so it can be used only once.
+// TokenHash is the hash of the link's signed token ID.
+type MagicLinkToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// EmailRevertToken lets the previous address undo an email change from a link in the notice
+// sent to it, in case the change was made by someone who took over the account.
+type EmailRevertToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	OldEmail  string    `gorm:"size:255;not null"`
+	NewEmail  string    `gorm:"size:255;not null"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// AccountDeletionToken is an emailed code that confirms a request to delete the account.
+type AccountDeletionToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// AuthThrottle counts recent failed attempts for one key, such as an account or an IP address
+// on a given endpoint, and holds the lockout that results from them.
+type AuthThrottle struct {
+	ID            uint      `gorm:"primaryKey"`
+	Key           string    `gorm:"size:320;not null;uniqueIndex"`
+	Failures      int       `gorm:"not null;default:0"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// OIDCLogin is a login started at an external identity provider that has not come back yet.
+type OIDCLogin struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex"`
+	Provider     string    `gorm:"size:50;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	Nonce        string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Provider  string    `gorm:"size:50;not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject"`
+	Email     string    `gorm:"size:255"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// APIToken is a personal access token for scripts and CI. Scopes is a space-separated list.
+type APIToken struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"not null;index"`
+	Name       string    `gorm:"size:100;not null"`
+	Prefix     string    `gorm:"size:20;not null"`
+	TokenHash  string    `gorm:"size:64;not null;uniqueIndex"`
+	Scopes     string    `gorm:"size:255;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// AuditEvent records a security-relevant action. Rows are only ever inserted, scrubbed of
+// personal data when their user is purged, and deleted once they are older than the
+// retention period. ActorID is nil when the actor is unknown,
+// such as a failed login for an unregistered email.
+type AuditEvent struct {
+	ID         uint   `gorm:"primaryKey"`
+	ActorID    *uint  `gorm:"index"`
+	Action     string `gorm:"size:64;not null;index"`
+	TargetType string `gorm:"size:32"`
+	TargetID   *uint
+	// Identifier is what the actor identified themselves with, such as the email of a login
+	// attempt, so attempts against unknown or locked accounts can be traced
+	Identifier string    `gorm:"size:255;index"`
+	IP         string    `gorm:"size:45"`
+	UserAgent  string    `gorm:"size:255"`
+	Outcome    string    `gorm:"size:16;not null"`
+	Reason     string    `gorm:"size:255"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index"`
+}
+
+// All returns every model, in the order the database is migrated.
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Post{},
+		&Like{},
+		&RefreshToken{},
+		&PasswordResetToken{},
+		&AuthThrottle{},
+		&RecoveryCode{},
+		&OIDCLogin{},
+		&UserIdentity{},
+		&APIToken{},
+		&Session{},
+		&AccountDeletionToken{},
+		&EmailRevertToken{},
+		&MagicLinkToken{},
+		&AuditEvent{},
+		&Follow{},
+		&Block{},
+		&Mute{},
+		&HandleRedirect{},
+		&Mention{},
+	}
+}
@@ -0,0 +1,107 @@
+// Package rbac defines the roles users can have, the permissions each role grants
+// and the scopes that limit what API tokens may do with them.
+package rbac
+
+type Permission string
+
+const (
+	PostRead      Permission = "post:read"
+	PostCreate    Permission = "post:create"
+	PostEditOwn   Permission = "post:edit:own"
+	PostEditAny   Permission = "post:edit:any"
+	PostDeleteOwn Permission = "post:delete:own"
+	PostDeleteAny Permission = "post:delete:any"
+	LikeWrite     Permission = "like:write"
+	FollowWrite   Permission = "follow:write"
+	UserBan       Permission = "user:ban"
+	UserUnlock    Permission = "user:unlock"
+	UserSetRole   Permission = "user:role"
+	AuditRead     Permission = "audit:read"
+)
+
+// Scope limits an API token to part of its owner's permissions.
+type Scope string
+
+const (
+	ScopePostsRead    Scope = "posts:read"
+	ScopePostsWrite   Scope = "posts:write"
+	ScopeLikesWrite   Scope = "likes:write"
+	ScopeFollowsWrite Scope = "follows:write"
+)
+
+// permissionScopes maps permissions to the scope an API token needs to use them.
+// Permissions missing here, such as moderation, are never available to API tokens.
+var permissionScopes = map[Permission]Scope{
+	PostRead:      ScopePostsRead,
+	PostCreate:    ScopePostsWrite,
+	PostEditOwn:   ScopePostsWrite,
+	PostEditAny:   ScopePostsWrite,
+	PostDeleteOwn: ScopePostsWrite,
+	PostDeleteAny: ScopePostsWrite,
+	LikeWrite:     ScopeLikesWrite,
+	FollowWrite:   ScopeFollowsWrite,
+}
+
+var validScopes = map[Scope]bool{
+	ScopePostsRead:    true,
+	ScopePostsWrite:   true,
+	ScopeLikesWrite:   true,
+	ScopeFollowsWrite: true,
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var userPermissions = []Permission{PostRead, PostCreate, PostEditOwn, PostDeleteOwn, LikeWrite, FollowWrite}
+
+var moderatorPermissions = append([]Permission{PostEditAny, PostDeleteAny, UserBan, UserUnlock}, userPermissions...)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:      userPermissions,
+	RoleModerator: moderatorPermissions,
+	RoleAdmin:     append([]Permission{UserSetRole, AuditRead}, moderatorPermissions...),
+}
+
+// rank orders roles so that users can only act on roles below their own.
+var rank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Has reports whether role grants permission. Unknown roles grant nothing.
+func Has(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidRole reports whether role is one of the defined roles.
+func ValidRole(role string) bool {
+	_, ok := rank[role]
+	return ok
+}
+
+// Outranks reports whether role is strictly higher than other, e.g. to let moderators
+// ban users but not other moderators.
+func Outranks(role, other string) bool {
+	return rank[role] > rank[other]
+}
+
+// ValidScope reports whether scope is one of the defined scopes.
+func ValidScope(scope Scope) bool {
+	return validScopes[scope]
+}
+
+// ScopeFor returns the scope an API token needs for permission, and false if
+// API tokens cannot use it at all.
+func ScopeFor(permission Permission) (Scope, bool) {
+	scope, ok := permissionScopes[permission]
+	return scope, ok
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"blog/internal/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AccountRepository struct {
+	DB *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) *AccountRepository {
+	return &AccountRepository{DB: db}
+}
+
+func (r *AccountRepository) CreateDeletionToken(token *models.AccountDeletionToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *AccountRepository) GetDeletionTokenByHash(hash string) (*models.AccountDeletionToken, error) {
+	var token models.AccountDeletionToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateDeletionTokens marks every outstanding deletion token of the user as used.
+func (r *AccountRepository) InvalidateDeletionTokens(userID uint) error {
+	return r.DB.Model(&models.AccountDeletionToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// MarkDeletionTokenUsed reports whether this call consumed the token.
+func (r *AccountRepository) MarkDeletionTokenUsed(id uint) (bool, error) {
+	res := r.DB.Model(&models.AccountDeletionToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+// GetDueDeletions returns the users whose deletion grace period has ended.
+func (r *AccountRepository) GetDueDeletions(now time.Time) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Where("deletion_scheduled_at <= ?", now).Find(&users).Error
+	return users, err
+}
+
+// Purge removes the user's personal data in one transaction. Credentials, sessions and linked
+// identities are always deleted and the user row is scrubbed and soft-deleted, so posts and likes
+// that are kept no longer point at anyone identifiable. The user's audit events are kept until
+// they expire, without the email addresses, IP addresses and user agents they were recorded with.
+func (r *AccountRepository) Purge(userID uint, deletePosts, deleteLikes bool) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("email").First(&user, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AuditEvent{}).
+			Where("actor_id = ? OR (target_type = ? AND target_id = ?) OR identifier = ?", userID, "user", userID, user.Email).
+			Updates(map[string]interface{}{"identifier": "", "ip": "", "user_agent": ""}).Error; err != nil {
+			return err
+		}
+
+		if deleteLikes {
+			if err := tx.Where("user_id = ?", userID).Delete(&models.Like{}).Error; err != nil {
+				return err
+			}
+		}
+		if deletePosts {
+			postIDs := tx.Unscoped().Model(&models.Post{}).Select("id").Where("user_id = ?", userID)
+			if err := tx.Where("post_id IN (?)", postIDs).Delete(&models.Like{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN (?)", postIDs).Delete(&models.Mention{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.Post{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("follower_id = ? OR followee_id = ?", userID, userID).Delete(&models.Follow{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blocker_id = ? OR blocked_id = ?", userID, userID).Delete(&models.Block{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("muter_id = ? OR muted_id = ?", userID, userID).Delete(&models.Mute{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.Session{},
+			&models.RefreshToken{},
+			&models.APIToken{},
+			&models.PasswordResetToken{},
+			&models.AccountDeletionToken{},
+			&models.EmailRevertToken{},
+			&models.MagicLinkToken{},
+			&models.HandleRedirect{},
+			&models.Mention{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"name":                    "Deleted user",
+			"handle":                  nil,
+			"display_name":            "",
+			"bio":                     "",
+			"website":                 "",
+			"avatar_url":              "",
+			"email":                   fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+			"pending_email":           "",
+			"password":                "",
+			"verification_code":       "",
+			"totp_secret":             "",
+			"totp_enabled":            false,
+			"deletion_scheduled_at":   nil,
+			"verification_expires_at": nil,
+			"verification_sent_at":    nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
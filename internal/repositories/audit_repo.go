@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"blog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository stores the audit log. It has no update methods on purpose: events are
+// appended and only removed by DeleteBefore once they are past retention. The one exception
+// is AccountRepository.Purge, which scrubs the personal data of a deleted user's events.
+type AuditRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// AuditFilter selects audit events. Zero fields do not filter.
+type AuditFilter struct {
+	UserID     uint
+	Identifier string
+	Action     string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+func (r *AuditRepository) Create(event *models.AuditEvent) error {
+	return r.DB.Create(event).Error
+}
+
+// List returns matching events, newest first. UserID matches events the user
+// performed as well as events that targeted the user.
+func (r *AuditRepository) List(filter AuditFilter) ([]models.AuditEvent, error) {
+	query := r.DB.Model(&models.AuditEvent{})
+	if filter.UserID != 0 {
+		query = query.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", filter.UserID, "user", filter.UserID)
+	}
+	if filter.Identifier != "" {
+		query = query.Where("identifier = ?", filter.Identifier)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var events []models.AuditEvent
+	err := query.Order("created_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&events).Error
+	return events, err
+}
+
+// DeleteBefore removes events created before t and returns how many were removed.
+func (r *AuditRepository) DeleteBefore(t time.Time) (int64, error) {
+	res := r.DB.Where("created_at < ?", t).Delete(&models.AuditEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"blog/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// BlockRepository stores blocks and mutes between users.
+type BlockRepository struct {
+	DB *gorm.DB
+}
+
+func NewBlockRepository(db *gorm.DB) *BlockRepository {
+	return &BlockRepository{DB: db}
+}
+
+// CreateBlock adds the block and removes any follow between the two users, as blocked users
+// may not follow the blocker. Blocking someone twice returns gorm.ErrDuplicatedKey.
+func (r *BlockRepository) CreateBlock(block *models.Block) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(block).Error; err != nil {
+			return err
+		}
+		return tx.Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)",
+			block.BlockerID, block.BlockedID, block.BlockedID, block.BlockerID).
+			Delete(&models.Follow{}).Error
+	})
+}
+
+// DeleteBlock removes the block and reports whether there was one.
+func (r *BlockRepository) DeleteBlock(blockerID, blockedID uint) (bool, error) {
+	res := r.DB.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Delete(&models.Block{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// IsBlocked reports whether blockerID has blocked blockedID.
+func (r *BlockRepository) IsBlocked(blockerID, blockedID uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.Block{}).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// WithoutBlockers returns the users among userIDs who have not blocked blockedID.
+func (r *BlockRepository) WithoutBlockers(blockedID uint, userIDs []uint) ([]uint, error) {
+	if len(userIDs) == 0 {
+		return userIDs, nil
+	}
+	var blockers []uint
+	err := r.DB.Model(&models.Block{}).
+		Where("blocked_id = ? AND blocker_id IN ?", blockedID, userIDs).
+		Pluck("blocker_id", &blockers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	isBlocker := make(map[uint]bool, len(blockers))
+	for _, id := range blockers {
+		isBlocker[id] = true
+	}
+	var result []uint
+	for _, id := range userIDs {
+		if !isBlocker[id] {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// ListBlocked returns the users blockerID has blocked, most recent first.
+func (r *BlockRepository) ListBlocked(blockerID uint, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Joins("JOIN blocks ON blocks.blocked_id = users.id").
+		Where("blocks.blocker_id = ?", blockerID).
+		Order("blocks.created_at DESC, blocks.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+// GetBlocksByUserID returns the blocks blockerID has made, oldest first.
+func (r *BlockRepository) GetBlocksByUserID(blockerID uint) ([]models.Block, error) {
+	var blocks []models.Block
+	err := r.DB.Where("blocker_id = ?", blockerID).Order("created_at").Find(&blocks).Error
+	return blocks, err
+}
+
+// CreateMute adds the mute. Muting someone twice returns gorm.ErrDuplicatedKey.
+func (r *BlockRepository) CreateMute(mute *models.Mute) error {
+	return r.DB.Create(mute).Error
+}
+
+// DeleteMute removes the mute and reports whether there was one.
+func (r *BlockRepository) DeleteMute(muterID, mutedID uint) (bool, error) {
+	res := r.DB.Where("muter_id = ? AND muted_id = ?", muterID, mutedID).Delete(&models.Mute{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListMuted returns the users muterID has muted, most recent first.
+func (r *BlockRepository) ListMuted(muterID uint, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Joins("JOIN mutes ON mutes.muted_id = users.id").
+		Where("mutes.muter_id = ?", muterID).
+		Order("mutes.created_at DESC, mutes.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+// GetMutesByUserID returns the mutes muterID has made, oldest first.
+func (r *BlockRepository) GetMutesByUserID(muterID uint) ([]models.Mute, error) {
+	var mutes []models.Mute
+	err := r.DB.Where("muter_id = ?", muterID).Order("created_at").Find(&mutes).Error
+	return mutes, err
+}
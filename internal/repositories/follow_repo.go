@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"blog/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type FollowRepository struct {
+	DB *gorm.DB
+}
+
+func NewFollowRepository(db *gorm.DB) *FollowRepository {
+	return &FollowRepository{DB: db}
+}
+
+// Create adds the follow. Following someone twice violates a unique index and
+// returns gorm.ErrDuplicatedKey.
+func (r *FollowRepository) Create(follow *models.Follow) error {
+	return r.DB.Create(follow).Error
+}
+
+// Delete removes the follow and reports whether there was one.
+func (r *FollowRepository) Delete(followerID, followeeID uint) (bool, error) {
+	res := r.DB.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&models.Follow{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListFollowers returns the users following userID, most recent follow first.
+func (r *FollowRepository) ListFollowers(userID uint, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Joins("JOIN follows ON follows.follower_id = users.id").
+		Where("follows.followee_id = ?", userID).
+		Order("follows.created_at DESC, follows.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+// ListFollowing returns the users userID follows, most recent follow first.
+func (r *FollowRepository) ListFollowing(userID uint, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Joins("JOIN follows ON follows.followee_id = users.id").
+		Where("follows.follower_id = ?", userID).
+		Order("follows.created_at DESC, follows.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+// GetFollowsByUserID returns the follows userID has made or received, oldest first.
+func (r *FollowRepository) GetFollowsByUserID(userID uint) ([]models.Follow, error) {
+	var follows []models.Follow
+	err := r.DB.Where("follower_id = ? OR followee_id = ?", userID, userID).Order("created_at").Find(&follows).Error
+	return follows, err
+}
+
+// Counts returns how many users follow userID and how many users userID follows.
+// Follows of deleted accounts are not counted.
+func (r *FollowRepository) Counts(userID uint) (int64, int64, error) {
+	var followers, following int64
+	err := r.DB.Model(&models.User{}).
+		Joins("JOIN follows ON follows.follower_id = users.id").
+		Where("follows.followee_id = ?", userID).
+		Count(&followers).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	err = r.DB.Model(&models.User{}).
+		Joins("JOIN follows ON follows.followee_id = users.id").
+		Where("follows.follower_id = ?", userID).
+		Count(&following).Error
+	return followers, following, err
+}
@@ -27,3 +27,10 @@ func (r *LikeRepository) GetLikesCount(postID uint) (int64, error) {
 	err := r.DB.Model(&models.Like{}).Where("post_id = ?", postID).Count(&count).Error
 	return count, err
 }
+
+// GetLikesByUserID returns the likes the user has given, oldest first.
+func (r *LikeRepository) GetLikesByUserID(userID uint) ([]models.Like, error) {
+	var likes []models.Like
+	err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&likes).Error
+	return likes, err
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"blog/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type MentionRepository struct {
+	DB *gorm.DB
+}
+
+func NewMentionRepository(db *gorm.DB) *MentionRepository {
+	return &MentionRepository{DB: db}
+}
+
+// GetMentionedUsers returns the users mentioned in the post.
+func (r *MentionRepository) GetMentionedUsers(postID uint) ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Joins("JOIN mentions ON mentions.user_id = users.id").
+		Where("mentions.post_id = ?", postID).
+		Order("mentions.id").
+		Find(&users).Error
+	return users, err
+}
+
+// GetMentioningPosts returns the newest posts that mention userID, leaving out authors
+// userID has muted and authors on either side of a block with userID.
+func (r *MentionRepository) GetMentioningPosts(userID uint, limit, offset int) ([]models.Post, error) {
+	muted := r.DB.Model(&models.Mute{}).Select("muted_id").Where("muter_id = ?", userID)
+	blocking := r.DB.Model(&models.Block{}).Select("blocker_id").Where("blocked_id = ?", userID)
+	blocked := r.DB.Model(&models.Block{}).Select("blocked_id").Where("blocker_id = ?", userID)
+
+	var posts []models.Post
+	err := r.DB.Joins("JOIN mentions ON mentions.post_id = posts.id").
+		Where("mentions.user_id = ?", userID).
+		Where("posts.user_id NOT IN (?)", muted).
+		Where("posts.user_id NOT IN (?)", blocking).
+		Where("posts.user_id NOT IN (?)", blocked).
+		Order("posts.created_at DESC, posts.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&posts).Error
+	return posts, err
+}
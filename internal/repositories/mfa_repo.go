@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"blog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MFARepository struct {
+	DB *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) *MFARepository {
+	return &MFARepository{DB: db}
+}
+
+// ReplaceRecoveryCodes deletes every recovery code of the user and stores the new ones.
+func (r *MFARepository) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks a matching unused code as used and reports whether there was one.
+func (r *MFARepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	res := r.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
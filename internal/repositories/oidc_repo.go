@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"blog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OIDCRepository struct {
+	DB *gorm.DB
+}
+
+func NewOIDCRepository(db *gorm.DB) *OIDCRepository {
+	return &OIDCRepository{DB: db}
+}
+
+func (r *OIDCRepository) CreateLogin(login *models.OIDCLogin) error {
+	return r.DB.Create(login).Error
+}
+
+// ConsumeLogin deletes and returns the pending login for the state hash, so each state
+// can be redeemed only once. Expired logins are cleaned up on the way.
+func (r *OIDCRepository) ConsumeLogin(stateHash string) (*models.OIDCLogin, error) {
+	if err := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLogin{}).Error; err != nil {
+		return nil, err
+	}
+
+	var logins []models.OIDCLogin
+	err := r.DB.Clauses(clause.Returning{}).Where("state_hash = ?", stateHash).Delete(&logins).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(logins) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &logins[0], nil
+}
+
+func (r *OIDCRepository) GetIdentity(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.DB.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *OIDCRepository) CreateIdentity(identity *models.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
+
+func (r *OIDCRepository) DeleteIdentity(id uint) error {
+	return r.DB.Delete(&models.UserIdentity{}, id).Error
+}
@@ -14,8 +14,38 @@ func NewPostRepository(db *gorm.DB) *PostRepository {
 	return &PostRepository{DB: db}
 }
 
-func (r *PostRepository) CreatePost(post *models.Post) error {
-	return r.DB.Create(post).Error
+// CreatePost creates the post together with its mentions of mentionedIDs.
+func (r *PostRepository) CreatePost(post *models.Post, mentionedIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		return createMentions(tx, post.ID, mentionedIDs)
+	})
+}
+
+// UpdatePost saves the post and replaces its mentions with mentionedIDs.
+func (r *PostRepository) UpdatePost(post *models.Post, mentionedIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(post).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.Mention{}).Error; err != nil {
+			return err
+		}
+		return createMentions(tx, post.ID, mentionedIDs)
+	})
+}
+
+func createMentions(tx *gorm.DB, postID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	mentions := make([]models.Mention, len(userIDs))
+	for i, userID := range userIDs {
+		mentions[i] = models.Mention{PostID: postID, UserID: userID}
+	}
+	return tx.Create(&mentions).Error
 }
 
 func (r *PostRepository) GetPostsByUserID(userID uint) ([]models.Post, error) {
@@ -24,6 +54,33 @@ func (r *PostRepository) GetPostsByUserID(userID uint) ([]models.Post, error) {
 	return posts, err
 }
 
+func (r *PostRepository) GetPostByID(postID uint) (*models.Post, error) {
+	var post models.Post
+	if err := r.DB.First(&post, postID).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
 func (r *PostRepository) DeletePost(postID uint) error {
 	return r.DB.Where("id = ?", postID).Delete(&models.Post{}).Error
 }
+
+// GetFeed returns the newest posts of the authors userID follows, leaving out authors
+// userID has muted and authors on either side of a block with userID.
+func (r *PostRepository) GetFeed(userID uint, limit, offset int) ([]models.Post, error) {
+	followed := r.DB.Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", userID)
+	muted := r.DB.Model(&models.Mute{}).Select("muted_id").Where("muter_id = ?", userID)
+	blocking := r.DB.Model(&models.Block{}).Select("blocker_id").Where("blocked_id = ?", userID)
+	blocked := r.DB.Model(&models.Block{}).Select("blocked_id").Where("blocker_id = ?", userID)
+
+	var posts []models.Post
+	err := r.DB.Where("user_id IN (?)", followed).
+		Where("user_id NOT IN (?)", muted).
+		Where("user_id NOT IN (?)", blocking).
+		Where("user_id NOT IN (?)", blocked).
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&posts).Error
+	return posts, err
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"blog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ThrottleRepository struct {
+	DB *gorm.DB
+}
+
+func NewThrottleRepository(db *gorm.DB) *ThrottleRepository {
+	return &ThrottleRepository{DB: db}
+}
+
+// RecordFailure atomically increments the failure counter for key and returns the updated row.
+// Failures older than window no longer count, so the counter starts over.
+func (r *ThrottleRepository) RecordFailure(key string, window time.Duration) (*models.AuthThrottle, error) {
+	now := time.Now()
+	throttle := models.AuthThrottle{Key: key, Failures: 1, LastFailureAt: now}
+	err := r.DB.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN auth_throttles.last_failure_at < ? THEN 1 ELSE auth_throttles.failures + 1 END", now.Add(-window)),
+				"last_failure_at": now,
+				"updated_at":      now,
+			}),
+		},
+		clause.Returning{},
+	).Create(&throttle).Error
+	return &throttle, err
+}
+
+func (r *ThrottleRepository) Lock(key string, until time.Time) error {
+	return r.DB.Model(&models.AuthThrottle{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+// LockedUntil returns the latest lockout among keys that is still in effect, or nil.
+func (r *ThrottleRepository) LockedUntil(keys []string) (*time.Time, error) {
+	var throttle models.AuthThrottle
+	err := r.DB.Where("key IN ? AND locked_until > ?", keys, time.Now()).
+		Order("locked_until DESC").
+		Limit(1).
+		Find(&throttle).Error
+	if err != nil || throttle.ID == 0 {
+		return nil, err
+	}
+	return throttle.LockedUntil, nil
+}
+
+func (r *ThrottleRepository) Delete(keys []string) error {
+	return r.DB.Where("key IN ?", keys).Delete(&models.AuthThrottle{}).Error
+}
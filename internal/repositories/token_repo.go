@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"blog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{DB: db}
+}
+
+func (r *TokenRepository) CreateSession(session *models.Session) error {
+	return r.DB.Create(session).Error
+}
+
+func (r *TokenRepository) GetSession(id string) (*models.Session, error) {
+	var session models.Session
+	if err := r.DB.Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListSessions returns the user's sessions that have not been revoked, most recently used first.
+func (r *TokenRepository) ListSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *TokenRepository) TouchSession(id, ip, userAgent string, seenAt time.Time) error {
+	return r.DB.Model(&models.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"ip":           ip,
+		"user_agent":   userAgent,
+		"last_seen_at": seenAt,
+	}).Error
+}
+
+// RevokeSession revokes the session and its refresh tokens if it belongs to the user,
+// and reports whether it did.
+func (r *TokenRepository) RevokeSession(userID uint, id string) (bool, error) {
+	var revoked bool
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		revoked = res.RowsAffected == 1
+		if !revoked {
+			return nil
+		}
+		return revokeFamily(tx, id)
+	})
+	return revoked, err
+}
+
+func (r *TokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *TokenRepository) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed marks the token as used and reports whether this call
+// was the one that did it, so concurrent refreshes with the same token are detected.
+func (r *TokenRepository) MarkRefreshTokenUsed(id uint) (bool, error) {
+	res := r.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+// RevokeFamily revokes every refresh token of a login together with its session.
+func (r *TokenRepository) RevokeFamily(familyID string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return revokeFamily(tx, familyID)
+	})
+}
+
+// RevokeAllForUser revokes every session, refresh token and API token of the user.
+func (r *TokenRepository) RevokeAllForUser(userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, model := range []interface{}{&models.Session{}, &models.RefreshToken{}, &models.APIToken{}} {
+			if err := tx.Model(model).
+				Where("user_id = ? AND revoked_at IS NULL", userID).
+				Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func revokeFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *TokenRepository) CreatePasswordResetToken(token *models.PasswordResetToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *TokenRepository) GetPasswordResetTokenByHash(hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidatePasswordResetTokens marks every outstanding reset token of the user as used.
+func (r *TokenRepository) InvalidatePasswordResetTokens(userID uint) error {
+	return r.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// MarkPasswordResetTokenUsed reports whether this call consumed the token.
+func (r *TokenRepository) MarkPasswordResetTokenUsed(id uint) (bool, error) {
+	res := r.DB.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *TokenRepository) CreateMagicLinkToken(token *models.MagicLinkToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *TokenRepository) GetMagicLinkTokenByHash(hash string) (*models.MagicLinkToken, error) {
+	var token models.MagicLinkToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetLatestMagicLinkToken returns the most recently created magic link of the user.
+func (r *TokenRepository) GetLatestMagicLinkToken(userID uint) (*models.MagicLinkToken, error) {
+	var token models.MagicLinkToken
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateMagicLinkTokens marks every outstanding magic link of the user as used.
+func (r *TokenRepository) InvalidateMagicLinkTokens(userID uint) error {
+	return r.DB.Model(&models.MagicLinkToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// MarkMagicLinkTokenUsed reports whether this call consumed the token.
+func (r *TokenRepository) MarkMagicLinkTokenUsed(id uint) (bool, error) {
+	res := r.DB.Model(&models.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *TokenRepository) CreateEmailRevertToken(token *models.EmailRevertToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *TokenRepository) GetEmailRevertTokenByHash(hash string) (*models.EmailRevertToken, error) {
+	var token models.EmailRevertToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkEmailRevertTokenUsed reports whether this call consumed the token.
+func (r *TokenRepository) MarkEmailRevertTokenUsed(id uint) (bool, error) {
+	res := r.DB.Model(&models.EmailRevertToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *TokenRepository) CreateAPIToken(token *models.APIToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *TokenRepository) GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListAPITokens returns the user's tokens that have not been revoked, newest first.
+func (r *TokenRepository) ListAPITokens(userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := r.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeAPIToken revokes the token if it belongs to the user and reports whether it did.
+func (r *TokenRepository) RevokeAPIToken(userID, tokenID uint) (bool, error) {
+	res := r.DB.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *TokenRepository) TouchAPIToken(tokenID uint, usedAt time.Time) error {
+	return r.DB.Model(&models.APIToken{}).Where("id = ?", tokenID).Update("last_used_at", usedAt).Error
+}
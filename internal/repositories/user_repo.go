@@ -2,6 +2,9 @@ package repositories
 
 import (
 	"blog/internal/models"
+	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -32,6 +35,113 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+func (r *UserRepository) GetByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.DB.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) Update(user *models.User) error {
 	return r.DB.Save(user).Error
 }
+
+// AdvanceTOTPStep records step as the user's last used TOTP step if it is newer and reports
+// whether it was, so a code replayed concurrently is accepted only once.
+func (r *UserRepository) AdvanceTOTPStep(userID uint, step int64) (bool, error) {
+	res := r.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *UserRepository) GetByHandle(handle string) (*models.User, error) {
+	var user models.User
+	if err := r.DB.Where("handle = ?", handle).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetHandleRedirect returns the redirect of a previous handle if it has not expired.
+func (r *UserRepository) GetHandleRedirect(handle string) (*models.HandleRedirect, error) {
+	var redirect models.HandleRedirect
+	if err := r.DB.Where("handle = ? AND expires_at > ?", handle, time.Now()).First(&redirect).Error; err != nil {
+		return nil, err
+	}
+	return &redirect, nil
+}
+
+// GetHandleRedirectsByUserID returns the redirects of the user's previous handles, oldest first.
+func (r *UserRepository) GetHandleRedirectsByUserID(userID uint) ([]models.HandleRedirect, error) {
+	var redirects []models.HandleRedirect
+	err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&redirects).Error
+	return redirects, err
+}
+
+// HandleAvailable reports whether userID may take handle: nobody else has it, and it does not
+// redirect to anyone else. Pass zero for a user that does not exist yet.
+func (r *UserRepository) HandleAvailable(handle string, userID uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.User{}).Where("handle = ? AND id <> ?", handle, userID).Count(&count).Error
+	if err != nil || count > 0 {
+		return false, err
+	}
+	err = r.DB.Model(&models.HandleRedirect{}).
+		Where("handle = ? AND user_id <> ? AND expires_at > ?", handle, userID, time.Now()).
+		Count(&count).Error
+	return count == 0, err
+}
+
+// ChangeHandle sets the user's handle. If the user had one, it redirects to them until
+// redirectUntil. Like a unique index violation, it returns gorm.ErrDuplicatedKey if the
+// new handle belongs to or redirects to someone else.
+func (r *UserRepository) ChangeHandle(user *models.User, handle string, redirectUntil time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// Expired redirects and the user's own old handles can be claimed
+		if err := tx.Where("handle = ? AND (user_id = ? OR expires_at <= ?)", handle, user.ID, time.Now()).
+			Delete(&models.HandleRedirect{}).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.HandleRedirect{}).Where("handle = ?", handle).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+
+		if user.Handle != nil && *user.Handle != handle {
+			// An expired redirect of the old handle, left from an earlier rename, would
+			// collide with the new one
+			if err := tx.Where("handle = ? AND expires_at <= ?", *user.Handle, time.Now()).
+				Delete(&models.HandleRedirect{}).Error; err != nil {
+				return err
+			}
+			redirect := &models.HandleRedirect{Handle: *user.Handle, UserID: user.ID, ExpiresAt: redirectUntil}
+			if err := tx.Create(redirect).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+				// Only a taken new handle is reported as a duplicate
+				return fmt.Errorf("redirect of handle %s already exists", *user.Handle)
+			} else if err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Update("handle", handle).Error
+	})
+}
+
+// ResolveHandles returns the IDs of the users with the given handles, including handles
+// that still redirect to a user.
+func (r *UserRepository) ResolveHandles(handles []string) ([]uint, error) {
+	var ids []uint
+	if len(handles) == 0 {
+		return ids, nil
+	}
+	err := r.DB.Raw(
+		"SELECT id FROM users WHERE handle IN ? AND deleted_at IS NULL "+
+			"UNION SELECT user_id FROM handle_redirects WHERE handle IN ? AND expires_at > ?",
+		handles, handles, time.Now(),
+	).Scan(&ids).Error
+	return ids, err
+}
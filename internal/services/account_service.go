@@ -0,0 +1,232 @@
+package services
+
+import (
+	"blog/config"
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrInvalidDeletionToken error = errors.New("invalid or expired deletion code")
+var ErrDeletionNotScheduled error = errors.New("account deletion is not scheduled")
+
+const (
+	DeletionPolicyDelete    = "delete"
+	DeletionPolicyAnonymize = "anonymize"
+
+	deletionTokenLifetime      = 30 * time.Minute
+	defaultDeletionGracePeriod = 30 * 24 * time.Hour
+)
+
+// AccountExport is everything the service stores about a user, for a data export.
+type AccountExport struct {
+	User  *models.User
+	Posts []models.Post
+	Likes []models.Like
+	// Follows holds both the follows the user has made and those of their followers
+	Follows []models.Follow
+	// Blocks and Mutes are only those the user has made; being blocked or muted is
+	// the other user's data
+	Blocks []models.Block
+	Mutes  []models.Mute
+	// PreviousHandles are the redirects left by renaming the user's handle
+	PreviousHandles []models.HandleRedirect
+}
+
+// AccountService handles data-subject requests: exporting a user's data and deleting the account.
+// Deletion is confirmed by an emailed code and takes effect after a grace period, during which
+// the user can cancel it.
+type AccountService struct {
+	UserRepo        *repositories.UserRepository
+	PostRepo        *repositories.PostRepository
+	LikeRepo        *repositories.LikeRepository
+	FollowRepo      *repositories.FollowRepository
+	BlockRepo       *repositories.BlockRepository
+	AccountRepo     *repositories.AccountRepository
+	ThrottleService *ThrottleService
+	AuditService    *AuditService
+}
+
+func NewAccountService(userRepo *repositories.UserRepository, postRepo *repositories.PostRepository, likeRepo *repositories.LikeRepository, followRepo *repositories.FollowRepository, blockRepo *repositories.BlockRepository, accountRepo *repositories.AccountRepository, throttleService *ThrottleService, auditService *AuditService) *AccountService {
+	return &AccountService{UserRepo: userRepo, PostRepo: postRepo, LikeRepo: likeRepo, FollowRepo: followRepo, BlockRepo: blockRepo, AccountRepo: accountRepo, ThrottleService: throttleService, AuditService: auditService}
+}
+
+// Export collects the user's profile, posts, the likes they have given, their follows
+// the users they have blocked or muted, and their previous handles.
+func (s *AccountService) Export(userID uint) (*AccountExport, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+	posts, err := s.PostRepo.GetPostsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	likes, err := s.LikeRepo.GetLikesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	follows, err := s.FollowRepo.GetFollowsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.BlockRepo.GetBlocksByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	mutes, err := s.BlockRepo.GetMutesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	previousHandles, err := s.UserRepo.GetHandleRedirectsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountExport{
+		User:            user,
+		Posts:           posts,
+		Likes:           likes,
+		Follows:         follows,
+		Blocks:          blocks,
+		Mutes:           mutes,
+		PreviousHandles: previousHandles,
+	}, nil
+}
+
+// RequestDeletion emails the user a code that confirms the deletion of their account.
+func (s *AccountService) RequestDeletion(userID uint) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := s.AccountRepo.InvalidateDeletionTokens(user.ID); err != nil {
+		return err
+	}
+	deletionToken := &models.AccountDeletionToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(deletionTokenLifetime),
+	}
+	if err := s.AccountRepo.CreateDeletionToken(deletionToken); err != nil {
+		return err
+	}
+	return sendAccountDeletionEmail(user.Email, token)
+}
+
+// ConfirmDeletion schedules the deletion of the account with a code from RequestDeletion
+// and returns when the account will be purged.
+func (s *AccountService) ConfirmDeletion(userID uint, token string, client ClientInfo) (time.Time, error) {
+	scheduledAt, err := s.confirmDeletion(userID, token)
+	s.audit(AuditAccountDeletion, userID, userID, client, err)
+	return scheduledAt, err
+}
+
+func (s *AccountService) confirmDeletion(userID uint, token string) (time.Time, error) {
+	deletionToken, err := s.AccountRepo.GetDeletionTokenByHash(hashToken(token))
+	if err != nil || deletionToken.UserID != userID {
+		return time.Time{}, ErrInvalidDeletionToken
+	}
+	if deletionToken.UsedAt != nil || time.Now().After(deletionToken.ExpiresAt) {
+		return time.Time{}, ErrInvalidDeletionToken
+	}
+	consumed, err := s.AccountRepo.MarkDeletionTokenUsed(deletionToken.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !consumed {
+		return time.Time{}, ErrInvalidDeletionToken
+	}
+
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return time.Time{}, ErrUNF
+	}
+	scheduledAt := time.Now().Add(deletionGracePeriod())
+	user.DeletionScheduledAt = &scheduledAt
+	if err := s.UserRepo.Update(user); err != nil {
+		return time.Time{}, err
+	}
+	return scheduledAt, nil
+}
+
+// CancelDeletion keeps the account if its deletion has not been carried out yet.
+func (s *AccountService) CancelDeletion(userID uint, client ClientInfo) error {
+	err := s.cancelDeletion(userID)
+	s.audit(AuditAccountRestore, userID, userID, client, err)
+	return err
+}
+
+func (s *AccountService) cancelDeletion(userID uint) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	if user.DeletionScheduledAt == nil {
+		return ErrDeletionNotScheduled
+	}
+	user.DeletionScheduledAt = nil
+	return s.UserRepo.Update(user)
+}
+
+// PurgeDueAccounts deletes every account whose grace period has ended, applying the
+// configured policy to its posts and likes, and returns how many were purged. An account
+// that fails to purge does not hold up the others; the failures are returned together.
+func (s *AccountService) PurgeDueAccounts() (int, error) {
+	users, err := s.AccountRepo.GetDueDeletions(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	deletePosts := config.AppConfig.AccountDeletion.Posts == DeletionPolicyDelete
+	deleteLikes := config.AppConfig.AccountDeletion.Likes != DeletionPolicyAnonymize
+	purged := 0
+	var errs []error
+	for _, user := range users {
+		// Purges are carried out by the server rather than a user, so there is no actor
+		err := s.AccountRepo.Purge(user.ID, deletePosts, deleteLikes)
+		s.audit(AuditAccountPurge, 0, user.ID, ClientInfo{}, err)
+		if err != nil {
+			log.Printf("Failed to purge user %d, %s", user.ID, err)
+			errs = append(errs, fmt.Errorf("user %d: %w", user.ID, err))
+			continue
+		}
+		// Lockout records are keyed by the email address
+		if err := s.ThrottleService.UnlockAccount(user.Email); err != nil {
+			log.Printf("Failed to remove lockouts of purged user %d, %s", user.ID, err)
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+func (s *AccountService) audit(action string, actorID, userID uint, client ClientInfo, err error) {
+	s.AuditService.Record(AuditEntry{
+		Action:     action,
+		ActorID:    actorID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+}
+
+func deletionGracePeriod() time.Duration {
+	if days := config.AppConfig.AccountDeletion.GracePeriodDays; days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return defaultDeletionGracePeriod
+}
+
+func sendAccountDeletionEmail(email, token string) error {
+	return sendEmail(email, "Confirm Account Deletion",
+		"Use this code to confirm the deletion of your account: "+token+"\n\n"+
+			"It expires in 30 minutes. If you did not ask to delete your account, change your password.")
+}
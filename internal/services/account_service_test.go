@@ -0,0 +1,102 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func newAccountService(e *testEnv) *AccountService {
+	return NewAccountService(
+		e.userRepo,
+		repositories.NewPostRepository(e.db),
+		repositories.NewLikeRepository(e.db),
+		repositories.NewFollowRepository(e.db),
+		repositories.NewBlockRepository(e.db),
+		repositories.NewAccountRepository(e.db),
+		e.throttle,
+		e.audit,
+	)
+}
+
+func scheduleDeletion(t *testing.T, e *testEnv, user *models.User) {
+	t.Helper()
+	due := time.Now().Add(-time.Minute)
+	user.DeletionScheduledAt = &due
+	if err := e.userRepo.Update(user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPurgeDueAccountsContinuesAfterAFailure(t *testing.T) {
+	e := newTestEnv(t)
+	accounts := newAccountService(e)
+
+	failing := e.createUser(t, "failing@example.com", rbac.RoleUser)
+	purgeable := e.createUser(t, "purgeable@example.com", rbac.RoleUser)
+	// The scrubbed email of the first user is taken, so its purge fails on the unique index
+	e.createUser(t, fmt.Sprintf("deleted-%d@deleted.invalid", failing.ID), rbac.RoleUser)
+	scheduleDeletion(t, e, failing)
+	scheduleDeletion(t, e, purgeable)
+
+	purged, err := accounts.PurgeDueAccounts()
+	if err == nil {
+		t.Error("PurgeDueAccounts returned no error for the account that failed")
+	}
+	if purged != 1 {
+		t.Errorf("purged %d accounts, want 1", purged)
+	}
+
+	if _, err := e.userRepo.GetByID(purgeable.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("account after the failing one was not purged: %v", err)
+	}
+	kept, err := e.userRepo.GetByID(failing.ID)
+	if err != nil || kept.Email != "failing@example.com" {
+		t.Errorf("failed purge changed the account: %+v, %v", kept, err)
+	}
+
+	events := e.auditEvents(t, AuditAccountPurge)
+	outcomes := map[uint]string{}
+	for _, event := range events {
+		outcomes[*event.TargetID] = event.Outcome
+	}
+	if outcomes[failing.ID] != auditOutcomeFailure || outcomes[purgeable.ID] != auditOutcomeSuccess {
+		t.Errorf("purge audit outcomes = %v, want failure for %d and success for %d", outcomes, failing.ID, purgeable.ID)
+	}
+}
+
+func TestPurgeScrubsAuditEvents(t *testing.T) {
+	e := newTestEnv(t)
+	accounts := newAccountService(e)
+
+	user := e.createUser(t, "leaving@example.com", rbac.RoleUser)
+	other := e.createUser(t, "staying@example.com", rbac.RoleUser)
+	client := ClientInfo{IP: "203.0.113.7", UserAgent: "test-agent"}
+	e.audit.Record(AuditEntry{Action: AuditUserLogin, ActorID: user.ID, TargetType: AuditTargetUser, TargetID: user.ID, Identifier: user.Email, Client: client})
+	// A failed login for the address has no actor but still names the user
+	e.audit.Record(AuditEntry{Action: AuditUserLogin, Identifier: user.Email, Client: client, Err: ErrInvalidCredentials})
+	e.audit.Record(AuditEntry{Action: AuditUserLogin, ActorID: other.ID, TargetType: AuditTargetUser, TargetID: other.ID, Identifier: other.Email, Client: client})
+	scheduleDeletion(t, e, user)
+
+	if _, err := accounts.PurgeDueAccounts(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range e.auditEvents(t, AuditUserLogin) {
+		ofUser := event.Identifier == "" && (event.ActorID == nil || *event.ActorID == user.ID)
+		switch {
+		case event.ActorID != nil && *event.ActorID == other.ID:
+			if event.Identifier != other.Email || event.IP != client.IP {
+				t.Errorf("event of another user was scrubbed: %+v", event)
+			}
+		case !ofUser || event.IP != "" || event.UserAgent != "":
+			t.Errorf("event of the purged user was not scrubbed: %+v", event)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"blog/config"
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"log"
+	"time"
+)
+
+// Audited actions. Each is recorded with the outcome "success" or "failure".
+const (
+	AuditUserRegister      = "user.register"
+	AuditUserLogin         = "user.login"
+	AuditUserLogout        = "user.logout"
+	AuditUserVerifyEmail   = "user.verify_email"
+	AuditUserPasswordReset = "user.password_reset"
+	AuditUserEmailChange   = "user.email_change"
+	AuditUserEmailRevert   = "user.email_revert"
+	AuditUserBan           = "user.ban"
+	AuditUserUnban         = "user.unban"
+	AuditUserRoleChange    = "user.role_change"
+	AuditUserUnlock        = "user.unlock"
+	AuditIPUnlock          = "ip.unlock"
+	AuditMFAEnable         = "mfa.enable"
+	AuditMFADisable        = "mfa.disable"
+	AuditMFARecoveryCodes  = "mfa.recovery_codes_regenerate"
+	AuditAPITokenCreate    = "api_token.create"
+	AuditAPITokenRevoke    = "api_token.revoke"
+	AuditPostCreate        = "post.create"
+	AuditPostUpdate        = "post.update"
+	AuditPostDelete        = "post.delete"
+	AuditLikeAdd           = "like.add"
+	AuditLikeRemove        = "like.remove"
+	AuditAccountDeletion   = "account.deletion_scheduled"
+	AuditAccountRestore    = "account.deletion_cancelled"
+	AuditAccountPurge      = "account.purged"
+)
+
+const (
+	AuditTargetUser     = "user"
+	AuditTargetPost     = "post"
+	AuditTargetAPIToken = "api_token"
+
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+)
+
+// AuditEntry describes an action to record. ActorID and TargetID are zero when unknown;
+// Identifier is the email or other name the actor gave, if any.
+// A nil Err records a success; otherwise the error becomes the reason of a failure.
+type AuditEntry struct {
+	Action     string
+	ActorID    uint
+	TargetType string
+	TargetID   uint
+	Identifier string
+	Client     ClientInfo
+	Err        error
+}
+
+type AuditService struct {
+	AuditRepo *repositories.AuditRepository
+}
+
+func NewAuditService(auditRepo *repositories.AuditRepository) *AuditService {
+	return &AuditService{AuditRepo: auditRepo}
+}
+
+// Record appends entry to the audit log. A failure to write it is logged rather than
+// returned, so auditing never fails the action itself.
+func (s *AuditService) Record(entry AuditEntry) {
+	event := &models.AuditEvent{
+		ActorID:    optionalID(entry.ActorID),
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   optionalID(entry.TargetID),
+		Identifier: truncate(entry.Identifier, 255),
+		IP:         truncate(entry.Client.IP, 45),
+		UserAgent:  truncate(entry.Client.UserAgent, 255),
+		Outcome:    auditOutcomeSuccess,
+	}
+	if entry.Err != nil {
+		event.Outcome = auditOutcomeFailure
+		event.Reason = truncate(entry.Err.Error(), 255)
+	}
+	if err := s.AuditRepo.Create(event); err != nil {
+		log.Printf("Failed to record audit event %s, %s", entry.Action, err)
+	}
+}
+
+// ListEvents returns audit events matching filter, newest first, at most 200 at a time.
+func (s *AuditService) ListEvents(filter repositories.AuditFilter) ([]models.AuditEvent, error) {
+	filter.Limit, filter.Offset = pageBounds(filter.Limit, filter.Offset)
+	return s.AuditRepo.List(filter)
+}
+
+// PurgeExpired deletes the events older than the configured retention period and returns
+// how many were deleted. A retention of zero days keeps events forever.
+func (s *AuditService) PurgeExpired() (int64, error) {
+	days := config.AppConfig.Audit.RetentionDays
+	if days <= 0 {
+		return 0, nil
+	}
+	return s.AuditRepo.DeleteBefore(time.Now().AddDate(0, 0, -days))
+}
+
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
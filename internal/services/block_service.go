@@ -0,0 +1,109 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrBlocked error = errors.New("blocked")
+var ErrSelfBlock error = errors.New("you cannot block or mute yourself")
+var ErrAlreadyBlocked error = errors.New("user is already blocked")
+var ErrNotBlocked error = errors.New("user is not blocked")
+var ErrAlreadyMuted error = errors.New("user is already muted")
+var ErrNotMuted error = errors.New("user is not muted")
+
+// BlockService manages blocks and mutes. A blocked user cannot like the blocker's posts,
+// follow the blocker or read the blocker's posts and follower lists while signed in, and
+// blocking removes any follow between the two. A mute only hides the muted user's posts
+// from the muter's feed.
+type BlockService struct {
+	BlockRepo *repositories.BlockRepository
+	UserRepo  *repositories.UserRepository
+}
+
+func NewBlockService(blockRepo *repositories.BlockRepository, userRepo *repositories.UserRepository) *BlockService {
+	return &BlockService{BlockRepo: blockRepo, UserRepo: userRepo}
+}
+
+func (s *BlockService) Block(blockerID, blockedID uint) error {
+	if blockerID == blockedID {
+		return ErrSelfBlock
+	}
+	if _, err := s.UserRepo.GetByID(blockedID); err != nil {
+		return ErrUNF
+	}
+
+	err := s.BlockRepo.CreateBlock(&models.Block{BlockerID: blockerID, BlockedID: blockedID})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrAlreadyBlocked
+	}
+	return err
+}
+
+func (s *BlockService) Unblock(blockerID, blockedID uint) error {
+	deleted, err := s.BlockRepo.DeleteBlock(blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotBlocked
+	}
+	return nil
+}
+
+// ListBlocked returns a page of the users blockerID has blocked, most recent first.
+func (s *BlockService) ListBlocked(blockerID uint, limit, offset int) ([]models.User, error) {
+	limit, offset = pageBounds(limit, offset)
+	return s.BlockRepo.ListBlocked(blockerID, limit, offset)
+}
+
+func (s *BlockService) Mute(muterID, mutedID uint) error {
+	if muterID == mutedID {
+		return ErrSelfBlock
+	}
+	if _, err := s.UserRepo.GetByID(mutedID); err != nil {
+		return ErrUNF
+	}
+
+	err := s.BlockRepo.CreateMute(&models.Mute{MuterID: muterID, MutedID: mutedID})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrAlreadyMuted
+	}
+	return err
+}
+
+func (s *BlockService) Unmute(muterID, mutedID uint) error {
+	deleted, err := s.BlockRepo.DeleteMute(muterID, mutedID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotMuted
+	}
+	return nil
+}
+
+// ListMuted returns a page of the users muterID has muted, most recent first.
+func (s *BlockService) ListMuted(muterID uint, limit, offset int) ([]models.User, error) {
+	limit, offset = pageBounds(limit, offset)
+	return s.BlockRepo.ListMuted(muterID, limit, offset)
+}
+
+// checkNotBlocked returns ErrBlocked if ownerID has blocked viewerID. A zero viewerID is
+// an anonymous caller, who is never blocked.
+func checkNotBlocked(blockRepo *repositories.BlockRepository, ownerID, viewerID uint) error {
+	if viewerID == 0 {
+		return nil
+	}
+	blocked, err := blockRepo.IsBlocked(ownerID, viewerID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
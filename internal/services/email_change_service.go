@@ -0,0 +1,229 @@
+package services
+
+import (
+	"blog/config"
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrEmailTaken error = errors.New("email already exists")
+var ErrSameEmail error = errors.New("new email is the current email")
+var ErrNoPendingEmail error = errors.New("no email change is pending")
+var ErrInvalidRevertToken error = errors.New("invalid or expired revert link")
+
+const (
+	emailRevertTokenLifetime = 7 * 24 * time.Hour
+	defaultPublicURL         = "http://localhost:8080"
+)
+
+// EmailChangeService changes a user's email address. The new address must be verified with a
+// code before it replaces the old one, and the old address gets a link that reverts the change.
+// The code reuses the user's verification fields, which are unused once the account is verified.
+type EmailChangeService struct {
+	UserRepo        *repositories.UserRepository
+	TokenService    *TokenService
+	ThrottleService *ThrottleService
+	AuditService    *AuditService
+}
+
+func NewEmailChangeService(userRepo *repositories.UserRepository, tokenService *TokenService, throttleService *ThrottleService, auditService *AuditService) *EmailChangeService {
+	return &EmailChangeService{UserRepo: userRepo, TokenService: tokenService, ThrottleService: throttleService, AuditService: auditService}
+}
+
+// RequestChange sends a verification code to the new address. The current address stays
+// in effect until the code is confirmed.
+func (s *EmailChangeService) RequestChange(userID uint, newEmail string) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	// Until then the verification fields hold the registration code
+	if !user.IsVerified {
+		return ErrEmailNotVerified
+	}
+	newEmail = strings.TrimSpace(newEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return ErrSameEmail
+	}
+	exists, err := s.UserRepo.EmailExists(newEmail)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrEmailTaken
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendCooldown {
+		return ErrResendTooSoon
+	}
+
+	code, err := setVerificationCode(user)
+	if err != nil {
+		return err
+	}
+	// Sending first means a failed send leaves no pending address behind
+	if err := sendVerificationEmail(newEmail, code); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	user.PendingEmail = newEmail
+	return s.UserRepo.Update(user)
+}
+
+// ConfirmChange switches the user to the pending address if code matches, and sends the
+// previous address a notice with a revert link.
+func (s *EmailChangeService) ConfirmChange(userID uint, code string, client ClientInfo) error {
+	err := s.confirmChange(userID, code, client.IP)
+	s.audit(AuditUserEmailChange, userID, userID, client, err)
+	return err
+}
+
+func (s *EmailChangeService) confirmChange(userID uint, code, ip string) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	if err := s.ThrottleService.Check(ThrottleScopeVerify, user.Email, ip); err != nil {
+		return err
+	}
+	if user.PendingEmail == "" {
+		return ErrNoPendingEmail
+	}
+	if user.VerificationAttempts >= maxVerificationAttempts {
+		return ErrTooManyAttempts
+	}
+	if user.VerificationExpiresAt == nil || time.Now().After(*user.VerificationExpiresAt) {
+		return ErrVerificationExpired
+	}
+
+	if subtle.ConstantTimeCompare([]byte(user.VerificationCode), []byte(hashToken(code))) != 1 {
+		user.VerificationAttempts++
+		if err := s.UserRepo.Update(user); err != nil {
+			return err
+		}
+		if err := s.ThrottleService.RecordFailure(ThrottleScopeVerify, user.Email, ip); err != nil {
+			return err
+		}
+		return ErrIVC
+	}
+
+	oldEmail := user.Email
+	user.Email = user.PendingEmail
+	clearPendingEmail(user)
+	// The address may have been registered since the change was requested
+	if err := s.UserRepo.Update(user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	if err := s.ThrottleService.Reset(ThrottleScopeVerify, oldEmail); err != nil {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	revertToken := &models.EmailRevertToken{
+		UserID:    user.ID,
+		OldEmail:  oldEmail,
+		NewEmail:  user.Email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(emailRevertTokenLifetime),
+	}
+	if err := s.TokenService.TokenRepo.CreateEmailRevertToken(revertToken); err != nil {
+		return err
+	}
+	go func() {
+		if err := sendEmailChangedEmail(oldEmail, user.Email, token); err != nil {
+			log.Printf("Failed to send email change notice to user %d, %s", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// RevertChange restores the address a revert link was sent to. Since the change may have
+// been made by someone else, every session is signed out and outstanding password resets
+// are invalidated.
+func (s *EmailChangeService) RevertChange(token string, client ClientInfo) error {
+	userID, err := s.revertChange(token)
+	s.audit(AuditUserEmailRevert, 0, userID, client, err)
+	return err
+}
+
+func (s *EmailChangeService) revertChange(token string) (uint, error) {
+	revertToken, err := s.TokenService.TokenRepo.GetEmailRevertTokenByHash(hashToken(token))
+	if err != nil {
+		return 0, ErrInvalidRevertToken
+	}
+	if revertToken.UsedAt != nil || time.Now().After(revertToken.ExpiresAt) {
+		return 0, ErrInvalidRevertToken
+	}
+	user, err := s.UserRepo.GetByID(revertToken.UserID)
+	if err != nil {
+		return 0, ErrInvalidRevertToken
+	}
+	consumed, err := s.TokenService.TokenRepo.MarkEmailRevertTokenUsed(revertToken.ID)
+	if err != nil {
+		return user.ID, err
+	}
+	if !consumed {
+		return 0, ErrInvalidRevertToken
+	}
+
+	user.Email = revertToken.OldEmail
+	user.IsVerified = true
+	clearPendingEmail(user)
+	if err := s.UserRepo.Update(user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return user.ID, ErrEmailTaken
+		}
+		return user.ID, err
+	}
+
+	if err := s.TokenService.TokenRepo.InvalidatePasswordResetTokens(user.ID); err != nil {
+		return user.ID, err
+	}
+	return user.ID, s.TokenService.RevokeAll(user.ID)
+}
+
+func (s *EmailChangeService) audit(action string, actorID, userID uint, client ClientInfo, err error) {
+	s.AuditService.Record(AuditEntry{
+		Action:     action,
+		ActorID:    actorID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+}
+
+func clearPendingEmail(user *models.User) {
+	user.PendingEmail = ""
+	user.VerificationCode = ""
+	user.VerificationExpiresAt = nil
+	user.VerificationAttempts = 0
+}
+
+func sendEmailChangedEmail(oldEmail, newEmail, token string) error {
+	link := publicURL() + "/email/revert?token=" + url.QueryEscape(token)
+	return sendEmail(oldEmail, "Email Address Changed",
+		"The email address of your account was changed to "+newEmail+".\n\n"+
+			"If you did not make this change, open this link within 7 days to restore this address "+
+			"and sign out everywhere, then reset your password:\n"+link)
+}
+
+func publicURL() string {
+	if u := config.AppConfig.Server.PublicURL; u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return defaultPublicURL
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrSelfFollow error = errors.New("you cannot follow yourself")
+var ErrAlreadyFollowing error = errors.New("already following this user")
+var ErrNotFollowing error = errors.New("not following this user")
+
+type FollowService struct {
+	FollowRepo *repositories.FollowRepository
+	UserRepo   *repositories.UserRepository
+	BlockRepo  *repositories.BlockRepository
+}
+
+func NewFollowService(followRepo *repositories.FollowRepository, userRepo *repositories.UserRepository, blockRepo *repositories.BlockRepository) *FollowService {
+	return &FollowService{FollowRepo: followRepo, UserRepo: userRepo, BlockRepo: blockRepo}
+}
+
+// Follow makes followerID follow followeeID. Neither of them may have blocked the other.
+func (s *FollowService) Follow(followerID, followeeID uint) error {
+	if followerID == followeeID {
+		return ErrSelfFollow
+	}
+	if _, err := s.UserRepo.GetByID(followeeID); err != nil {
+		return ErrUNF
+	}
+	if err := checkNotBlocked(s.BlockRepo, followeeID, followerID); err != nil {
+		return err
+	}
+	if err := checkNotBlocked(s.BlockRepo, followerID, followeeID); err != nil {
+		return err
+	}
+
+	err := s.FollowRepo.Create(&models.Follow{FollowerID: followerID, FolloweeID: followeeID})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrAlreadyFollowing
+	}
+	return err
+}
+
+func (s *FollowService) Unfollow(followerID, followeeID uint) error {
+	deleted, err := s.FollowRepo.Delete(followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFollowing
+	}
+	return nil
+}
+
+// ListFollowers returns a page of the users following userID, most recent first.
+// viewerID is the caller, or zero if anonymous; users cannot list the followers of
+// someone who has blocked them.
+func (s *FollowService) ListFollowers(userID, viewerID uint, limit, offset int) ([]models.User, error) {
+	if _, err := s.UserRepo.GetByID(userID); err != nil {
+		return nil, ErrUNF
+	}
+	if err := checkNotBlocked(s.BlockRepo, userID, viewerID); err != nil {
+		return nil, err
+	}
+	limit, offset = pageBounds(limit, offset)
+	return s.FollowRepo.ListFollowers(userID, limit, offset)
+}
+
+// ListFollowing returns a page of the users userID follows, most recent first,
+// with the same restriction as ListFollowers.
+func (s *FollowService) ListFollowing(userID, viewerID uint, limit, offset int) ([]models.User, error) {
+	if _, err := s.UserRepo.GetByID(userID); err != nil {
+		return nil, ErrUNF
+	}
+	if err := checkNotBlocked(s.BlockRepo, userID, viewerID); err != nil {
+		return nil, err
+	}
+	limit, offset = pageBounds(limit, offset)
+	return s.FollowRepo.ListFollowing(userID, limit, offset)
+}
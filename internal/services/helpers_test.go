@@ -0,0 +1,52 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"blog/internal/testutil"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// testEnv wires the services under test to a fresh in-memory database.
+type testEnv struct {
+	db       *gorm.DB
+	userRepo *repositories.UserRepository
+	audit    *AuditService
+	throttle *ThrottleService
+	tokens   *TokenService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	db := testutil.OpenDB(t)
+	audit := NewAuditService(repositories.NewAuditRepository(db))
+	return &testEnv{
+		db:       db,
+		userRepo: repositories.NewUserRepository(db),
+		audit:    audit,
+		throttle: NewThrottleService(repositories.NewThrottleRepository(db)),
+		tokens:   NewTokenService(repositories.NewTokenRepository(db), testutil.Keyring(t), audit),
+	}
+}
+
+// createUser stores a verified user with the given role.
+func (e *testEnv) createUser(t *testing.T, email, role string) *models.User {
+	t.Helper()
+	user := &models.User{Name: "Test User", Email: email, Password: "unusable", IsVerified: true, Role: role}
+	if err := e.userRepo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// auditEvents returns the recorded events with the given action, oldest first.
+func (e *testEnv) auditEvents(t *testing.T, action string) []models.AuditEvent {
+	t.Helper()
+	var events []models.AuditEvent
+	if err := e.db.Where("action = ?", action).Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
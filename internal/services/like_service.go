@@ -3,17 +3,41 @@ package services
 import (
 	"blog/internal/models"
 	"blog/internal/repositories"
+	"errors"
+
+	"gorm.io/gorm"
 )
 
 type LikeService struct {
-	LikeRepo *repositories.LikeRepository
+	LikeRepo     *repositories.LikeRepository
+	PostRepo     *repositories.PostRepository
+	BlockRepo    *repositories.BlockRepository
+	AuditService *AuditService
+}
+
+func NewLikeService(likeRepo *repositories.LikeRepository, postRepo *repositories.PostRepository, blockRepo *repositories.BlockRepository, auditService *AuditService) *LikeService {
+	return &LikeService{LikeRepo: likeRepo, PostRepo: postRepo, BlockRepo: blockRepo, AuditService: auditService}
 }
 
-func NewLikeService(likeRepo *repositories.LikeRepository) *LikeService {
-	return &LikeService{LikeRepo: likeRepo}
+// AddLike likes the post on behalf of userID, unless its author has blocked them.
+func (s *LikeService) AddLike(postID, userID uint, client ClientInfo) error {
+	err := s.addLike(postID, userID)
+	s.audit(AuditLikeAdd, postID, userID, client, err)
+	return err
 }
 
-func (s *LikeService) AddLike(postID, userID uint) error {
+func (s *LikeService) addLike(postID, userID uint) error {
+	post, err := s.PostRepo.GetPostByID(postID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkNotBlocked(s.BlockRepo, post.UserID, userID); err != nil {
+		return err
+	}
+
 	like := &models.Like{
 		PostID: postID,
 		UserID: userID,
@@ -21,8 +45,21 @@ func (s *LikeService) AddLike(postID, userID uint) error {
 	return s.LikeRepo.AddLike(like)
 }
 
-func (s *LikeService) RemoveLike(postID, userID uint) error {
-	return s.LikeRepo.RemoveLike(postID, userID)
+func (s *LikeService) RemoveLike(postID, userID uint, client ClientInfo) error {
+	err := s.LikeRepo.RemoveLike(postID, userID)
+	s.audit(AuditLikeRemove, postID, userID, client, err)
+	return err
+}
+
+func (s *LikeService) audit(action string, postID, userID uint, client ClientInfo, err error) {
+	s.AuditService.Record(AuditEntry{
+		Action:     action,
+		ActorID:    userID,
+		TargetType: AuditTargetPost,
+		TargetID:   postID,
+		Client:     client,
+		Err:        err,
+	})
 }
 
 func (s *LikeService) GetLikesCount(postID uint) (int64, error) {
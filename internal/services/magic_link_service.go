@@ -0,0 +1,127 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidMagicLink error = errors.New("invalid or expired login link")
+
+const (
+	magicLinkLifetime = 15 * time.Minute
+	magicLinkCooldown = time.Minute
+)
+
+// MagicLinkService logs users in with a signed link sent to their email address instead of
+// a password. Each link is recorded so it can be used only once.
+type MagicLinkService struct {
+	UserRepo     *repositories.UserRepository
+	TokenService *TokenService
+	UserService  *UserService
+}
+
+func NewMagicLinkService(userRepo *repositories.UserRepository, tokenService *TokenService, userService *UserService) *MagicLinkService {
+	return &MagicLinkService{UserRepo: userRepo, TokenService: tokenService, UserService: userService}
+}
+
+// RequestLink emails a login link to the user and invalidates earlier ones. Like
+// RequestPasswordReset it behaves the same whether or not the email is registered.
+func (s *MagicLinkService) RequestLink(email string) error {
+	user, err := s.UserRepo.GetByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Everything that only happens for existing accounts runs in the background, so the
+	// response time does not reveal whether the email is registered
+	go func() {
+		if err := s.sendLink(user); err != nil {
+			log.Printf("Failed to send login link to user %d, %s", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendLink replaces the user's login links with a new one and emails it, unless the user
+// is banned or was sent a link within the cooldown.
+func (s *MagicLinkService) sendLink(user *models.User) error {
+	if user.BannedAt != nil {
+		return nil
+	}
+	// Quietly drop repeated requests so the endpoint cannot be used to flood an inbox
+	if latest, err := s.TokenService.TokenRepo.GetLatestMagicLinkToken(user.ID); err == nil && time.Since(latest.CreatedAt) < magicLinkCooldown {
+		return nil
+	}
+
+	tokenID, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(magicLinkLifetime)
+	token, err := s.TokenService.IssueMagicLink(user.ID, tokenID, expiresAt)
+	if err != nil {
+		return err
+	}
+	if err := s.TokenService.TokenRepo.InvalidateMagicLinkTokens(user.ID); err != nil {
+		return err
+	}
+	magicLink := &models.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(tokenID),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.TokenService.TokenRepo.CreateMagicLinkToken(magicLink); err != nil {
+		return err
+	}
+	return sendMagicLinkEmail(user.Email, token)
+}
+
+// Verify exchanges a link from RequestLink for the same result as a password login,
+// subject to the same checks.
+func (s *MagicLinkService) Verify(token string, client ClientInfo) (*LoginResult, error) {
+	userID, tokenID, err := s.TokenService.ParseMagicLink(token)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	magicLink, err := s.TokenService.TokenRepo.GetMagicLinkTokenByHash(hashToken(tokenID))
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	if magicLink.UserID != userID || magicLink.UsedAt != nil || time.Now().After(magicLink.ExpiresAt) {
+		return nil, ErrInvalidMagicLink
+	}
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	// The link proves control of the address, but an unverified account still has to
+	// enter its verification code first, as with a password login. The link is kept for
+	// after that rather than spent on a login that cannot succeed.
+	if err := s.UserService.checkLoginAllowed(user, client); err != nil {
+		return nil, err
+	}
+	consumed, err := s.TokenService.TokenRepo.MarkMagicLinkTokenUsed(magicLink.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrInvalidMagicLink
+	}
+	return s.UserService.completeLogin(user, client)
+}
+
+func sendMagicLinkEmail(email, token string) error {
+	link := publicURL() + "/login/magic/verify?token=" + url.QueryEscape(token)
+	return sendEmail(email, "Your Login Link",
+		"Open this link to log in:\n"+link+"\n\n"+
+			"It expires in 15 minutes and can be used once. If you did not request it, you can ignore this email.")
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"blog/pkg/totp"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrMFAAlreadyEnabled error = errors.New("two-factor authentication is already enabled")
+var ErrMFANotEnabled error = errors.New("two-factor authentication is not enabled")
+var ErrMFANotEnrolling error = errors.New("two-factor enrollment has not been started")
+var ErrInvalidMFACode error = errors.New("invalid two-factor code")
+
+const (
+	totpIssuer        = "Go Blog"
+	recoveryCodeCount = 10
+)
+
+type MFAService struct {
+	UserRepo        *repositories.UserRepository
+	MFARepo         *repositories.MFARepository
+	TokenService    *TokenService
+	ThrottleService *ThrottleService
+	AuditService    *AuditService
+}
+
+// TOTPEnrollment is what the user needs to add the account to an authenticator app.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func NewMFAService(userRepo *repositories.UserRepository, mfaRepo *repositories.MFARepository, tokenService *TokenService, throttleService *ThrottleService, auditService *AuditService) *MFAService {
+	return &MFAService{UserRepo: userRepo, MFARepo: mfaRepo, TokenService: tokenService, ThrottleService: throttleService, AuditService: auditService}
+}
+
+// BeginTOTPEnrollment generates a new secret for the user. Two-factor authentication
+// stays disabled until ConfirmTOTPEnrollment proves the authenticator app was set up.
+func (s *MFAService) BeginTOTPEnrollment(userID uint) (*TOTPEnrollment, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := s.UserRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication once the user enters a valid code
+// and returns the recovery codes, which are shown only this once. Wrong codes count towards
+// the same lockout as at login.
+func (s *MFAService) ConfirmTOTPEnrollment(userID uint, code string, client ClientInfo) ([]string, error) {
+	codes, err := s.confirmTOTPEnrollment(userID, code, client.IP)
+	s.audit(AuditMFAEnable, userID, client, err)
+	return codes, err
+}
+
+func (s *MFAService) confirmTOTPEnrollment(userID uint, code, ip string) ([]string, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolling
+	}
+	if err := s.ThrottleService.Check(ThrottleScopeMFA, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		if err := s.ThrottleService.RecordFailure(ThrottleScopeMFA, user.Email, ip); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+	if err := s.ThrottleService.Reset(ThrottleScopeMFA, user.Email); err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	if err := s.UserRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+// DisableTOTP turns two-factor authentication off after checking a TOTP or recovery code.
+func (s *MFAService) DisableTOTP(userID uint, code, recoveryCode string, client ClientInfo) error {
+	err := s.disableTOTP(userID, code, recoveryCode, client.IP)
+	s.audit(AuditMFADisable, userID, client, err)
+	return err
+}
+
+func (s *MFAService) disableTOTP(userID uint, code, recoveryCode, ip string) error {
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifySecondFactor(user, code, recoveryCode, ip); err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	if err := s.UserRepo.Update(user); err != nil {
+		return err
+	}
+	return s.MFARepo.ReplaceRecoveryCodes(user.ID, nil)
+}
+
+// RegenerateRecoveryCodes invalidates all recovery codes of the user and returns new ones.
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string, client ClientInfo) ([]string, error) {
+	codes, err := s.regenerateRecoveryCodes(userID, code, client.IP)
+	s.audit(AuditMFARecoveryCodes, userID, client, err)
+	return codes, err
+}
+
+func (s *MFAService) regenerateRecoveryCodes(userID uint, code, ip string) ([]string, error) {
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(user, code, "", ip); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+// CompleteLogin finishes a login started by UserService.Login: it exchanges the MFA challenge
+// token and a TOTP or recovery code for a token pair.
+func (s *MFAService) CompleteLogin(mfaToken, code, recoveryCode string, client ClientInfo) (*TokenPair, error) {
+	userID, err := s.TokenService.ParseMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.completeLogin(user, code, recoveryCode, client)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditUserLogin,
+		ActorID:    user.ID,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+		Identifier: user.Email,
+		Client:     client,
+		Err:        err,
+	})
+	return tokens, err
+}
+
+func (s *MFAService) completeLogin(user *models.User, code, recoveryCode string, client ClientInfo) (*TokenPair, error) {
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+	if err := s.verifySecondFactor(user, code, recoveryCode, client.IP); err != nil {
+		return nil, err
+	}
+	return s.TokenService.Issue(user.ID, client)
+}
+
+func (s *MFAService) audit(action string, userID uint, client ClientInfo, err error) {
+	s.AuditService.Record(AuditEntry{
+		Action:     action,
+		ActorID:    userID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+}
+
+func (s *MFAService) enabledUser(userID uint) (*models.User, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+	return user, nil
+}
+
+// verifySecondFactor accepts either a TOTP code, which must be newer than the last one used,
+// or an unused recovery code. Failures count towards the same lockout as passwords.
+func (s *MFAService) verifySecondFactor(user *models.User, code, recoveryCode, ip string) error {
+	if err := s.ThrottleService.Check(ThrottleScopeMFA, user.Email, ip); err != nil {
+		return err
+	}
+
+	var valid bool
+	switch {
+	case code != "":
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if ok {
+			advanced, err := s.UserRepo.AdvanceTOTPStep(user.ID, step)
+			if err != nil {
+				return err
+			}
+			if advanced {
+				user.TOTPLastStep = step
+			}
+			valid = advanced
+		}
+	case recoveryCode != "":
+		used, err := s.MFARepo.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		valid = used
+	}
+
+	if !valid {
+		if err := s.ThrottleService.RecordFailure(ThrottleScopeMFA, user.Email, ip); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	}
+	return s.ThrottleService.Reset(ThrottleScopeMFA, user.Email)
+}
+
+func (s *MFAService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	stored := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		stored[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))}
+	}
+
+	if err := s.MFARepo.ReplaceRecoveryCodes(userID, stored); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code like "k3j9x-p2m7q" that is easy to write down.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"errors"
+	"time"
+)
+
+var ErrInvalidRole error = errors.New("invalid role")
+
+// ModerationService holds the actions moderators and admins take on other users.
+// Route middleware checks the permission; the service checks that the actor
+// outranks the target, so moderators cannot act on other moderators or admins.
+type ModerationService struct {
+	UserRepo        *repositories.UserRepository
+	TokenService    *TokenService
+	ThrottleService *ThrottleService
+	AuditService    *AuditService
+}
+
+func NewModerationService(userRepo *repositories.UserRepository, tokenService *TokenService, throttleService *ThrottleService, auditService *AuditService) *ModerationService {
+	return &ModerationService{UserRepo: userRepo, TokenService: tokenService, ThrottleService: throttleService, AuditService: auditService}
+}
+
+// BanUser blocks the user from logging in and signs them out everywhere.
+func (s *ModerationService) BanUser(actor *Principal, userID uint, client ClientInfo) error {
+	err := s.banUser(actor, userID)
+	s.audit(AuditUserBan, actor, userID, client, err)
+	return err
+}
+
+func (s *ModerationService) banUser(actor *Principal, userID uint) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	if !rbac.Outranks(actor.Role, user.Role) {
+		return ErrForbidden
+	}
+	if user.BannedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.BannedAt = &now
+	if err := s.UserRepo.Update(user); err != nil {
+		return err
+	}
+	return s.TokenService.RevokeAll(user.ID)
+}
+
+func (s *ModerationService) UnbanUser(actor *Principal, userID uint, client ClientInfo) error {
+	err := s.unbanUser(actor, userID)
+	s.audit(AuditUserUnban, actor, userID, client, err)
+	return err
+}
+
+func (s *ModerationService) unbanUser(actor *Principal, userID uint) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	if !rbac.Outranks(actor.Role, user.Role) {
+		return ErrForbidden
+	}
+
+	user.BannedAt = nil
+	return s.UserRepo.Update(user)
+}
+
+// SetRole changes the role of a user the actor outranks.
+func (s *ModerationService) SetRole(actor *Principal, userID uint, role string, client ClientInfo) error {
+	err := s.setRole(actor, userID, role)
+	s.audit(AuditUserRoleChange, actor, userID, client, err)
+	return err
+}
+
+func (s *ModerationService) setRole(actor *Principal, userID uint, role string) error {
+	if !rbac.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	if !rbac.Outranks(actor.Role, user.Role) {
+		return ErrForbidden
+	}
+
+	user.Role = role
+	return s.UserRepo.Update(user)
+}
+
+// UnlockUser lifts the failed-attempt lockouts of the user.
+func (s *ModerationService) UnlockUser(actor *Principal, userID uint, client ClientInfo) error {
+	err := s.unlockUser(userID)
+	s.audit(AuditUserUnlock, actor, userID, client, err)
+	return err
+}
+
+func (s *ModerationService) unlockUser(userID uint) error {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return ErrUNF
+	}
+	return s.ThrottleService.UnlockAccount(user.Email)
+}
+
+func (s *ModerationService) audit(action string, actor *Principal, userID uint, client ClientInfo, err error) {
+	s.AuditService.Record(AuditEntry{
+		Action:     action,
+		ActorID:    actor.UserID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"blog/pkg/oidc"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnknownProvider error = errors.New("unknown identity provider")
+var ErrInvalidOIDCState error = errors.New("invalid or expired login state")
+var ErrOIDCEmailNotVerified error = errors.New("identity provider did not verify the email address")
+
+// OIDCLoginLifetime is how long a login started with AuthURL can be completed.
+const OIDCLoginLifetime = 10 * time.Minute
+
+// OIDCService logs users in through external OpenID Connect providers.
+type OIDCService struct {
+	Providers   map[string]*oidc.Provider
+	OIDCRepo    *repositories.OIDCRepository
+	UserRepo    *repositories.UserRepository
+	UserService *UserService
+}
+
+func NewOIDCService(providers map[string]*oidc.Provider, oidcRepo *repositories.OIDCRepository, userRepo *repositories.UserRepository, userService *UserService) *OIDCService {
+	return &OIDCService{Providers: providers, OIDCRepo: oidcRepo, UserRepo: userRepo, UserService: userService}
+}
+
+// AuthURL starts a login at the provider and returns the URL to redirect the user to, and the
+// login's state. The state must be kept in the browser the login was started from, such as in
+// a cookie, and passed to Callback: without it, the callback cannot be completed elsewhere.
+func (s *OIDCService) AuthURL(providerName string) (string, string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	login := &models.OIDCLogin{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(OIDCLoginLifetime),
+	}
+	if err := s.OIDCRepo.CreateLogin(login); err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Callback finishes a login the provider redirected back with. The user is found by the linked
+// provider identity, otherwise by the verified email address, otherwise a new user is created.
+// The result is the same as for a password login, including the two-factor step.
+// browserState is the state AuthURL returned, as kept by the browser making the callback;
+// a callback from any other browser is rejected before the login is used up.
+func (s *OIDCService) Callback(providerName, state, browserState, code string, client ClientInfo) (*LoginResult, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	login, err := s.OIDCRepo.ConsumeLogin(hashToken(state))
+	if err != nil || login.Provider != providerName || time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Exchange(code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.findOrCreateUser(providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.UserService.completeLogin(user, client)
+}
+
+func (s *OIDCService) findOrCreateUser(providerName string, claims *oidc.Claims) (*models.User, error) {
+	identity, err := s.OIDCRepo.GetIdentity(providerName, claims.Subject)
+	if err == nil {
+		user, err := s.UserRepo.GetByID(identity.UserID)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, err
+		}
+		// The account the identity was linked to is gone, so treat this as a first login
+		if err := s.OIDCRepo.DeleteIdentity(identity.ID); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	email := claims.Email
+
+	user, err := s.UserRepo.GetByEmail(email)
+	switch {
+	case err == nil:
+		if !user.IsVerified {
+			// Whoever registered this email locally never proved they own it, while the provider
+			// just did. Replace the password so an unverified squatter cannot take over the account.
+			if err := s.resetToUnusablePassword(user); err != nil {
+				return nil, err
+			}
+			user.IsVerified = true
+			user.VerificationCode = ""
+			user.VerificationExpiresAt = nil
+			if err := s.UserRepo.Update(user); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		name := strings.TrimSpace(claims.Name)
+		if name == "" {
+			name = strings.Split(email, "@")[0]
+		}
+		// Providers put no limit on names, unlike the users table
+		user = &models.User{Name: truncate(name, 100), Email: email, IsVerified: true, Role: rbac.RoleUser}
+		if err := s.resetToUnusablePassword(user); err != nil {
+			return nil, err
+		}
+		if err := s.UserRepo.Create(user); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	identity = &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    email,
+	}
+	if err := s.OIDCRepo.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// resetToUnusablePassword sets a random password nobody knows; the user can choose a real one
+// through the password reset flow.
+func (s *OIDCService) resetToUnusablePassword(user *models.User) error {
+	random, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	hashedPass, err := s.UserService.Hasher.Hash(random)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPass
+	return nil
+}
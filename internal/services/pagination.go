@@ -0,0 +1,20 @@
+package services
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// pageBounds applies the default and maximum page size to a requested limit and offset.
+func pageBounds(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
@@ -1,26 +1,194 @@
 package services
 
 import (
+	"blog/internal/handle"
 	"blog/internal/models"
+	"blog/internal/rbac"
 	"blog/internal/repositories"
+	"errors"
+
+	"gorm.io/gorm"
 )
 
 type PostService struct {
-	PostRepo *repositories.PostRepository
+	PostRepo     *repositories.PostRepository
+	UserRepo     *repositories.UserRepository
+	MentionRepo  *repositories.MentionRepository
+	BlockRepo    *repositories.BlockRepository
+	AuditService *AuditService
+}
+
+var ErrPostNotFound error = errors.New("post not found")
+var ErrForbidden error = errors.New("forbidden")
+
+func NewPostService(postRepo *repositories.PostRepository, userRepo *repositories.UserRepository, mentionRepo *repositories.MentionRepository, blockRepo *repositories.BlockRepository, auditService *AuditService) *PostService {
+	return &PostService{PostRepo: postRepo, UserRepo: userRepo, MentionRepo: mentionRepo, BlockRepo: blockRepo, AuditService: auditService}
+}
+
+// CreatePostInput is what a client may set when creating a post.
+type CreatePostInput struct {
+	Title   string `json:"title" validate:"required,max=255"`
+	Content string `json:"content" validate:"required"`
 }
 
-func NewPostService(postRepo *repositories.PostRepository) *PostService {
-	return &PostService{PostRepo: postRepo}
+// PostUpdate holds the post fields to change; nil fields are left as they are.
+type PostUpdate struct {
+	Title   *string `json:"title" validate:"required,max=255"`
+	Content *string `json:"content" validate:"required"`
 }
 
-func (s *PostService) CreatePost(post *models.Post) error {
-	return s.PostRepo.CreatePost(post)
+// CreatePost creates a post authored by userID and records the users its content mentions.
+func (s *PostService) CreatePost(userID uint, input CreatePostInput, client ClientInfo) (*models.Post, error) {
+	post := &models.Post{
+		UserID:  userID,
+		Title:   input.Title,
+		Content: input.Content,
+	}
+	mentionedIDs, err := s.resolveMentions(userID, input.Content)
+	if err == nil {
+		err = s.PostRepo.CreatePost(post, mentionedIDs)
+	}
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditPostCreate,
+		ActorID:    userID,
+		TargetType: AuditTargetPost,
+		TargetID:   post.ID,
+		Client:     client,
+		Err:        err,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
 }
 
-func (s *PostService) GetPostsByUserID(userID uint) ([]models.Post, error) {
+// GetPostsByUserID returns the posts of userID. viewerID is the caller, or zero if
+// anonymous; users cannot list the posts of someone who has blocked them.
+func (s *PostService) GetPostsByUserID(userID, viewerID uint) ([]models.Post, error) {
+	if err := checkNotBlocked(s.BlockRepo, userID, viewerID); err != nil {
+		return nil, err
+	}
 	return s.PostRepo.GetPostsByUserID(userID)
 }
 
-func (s *PostService) DeletePost(postID uint) error {
+// GetMentionedUsers returns the users mentioned in the post. viewerID is the caller,
+// or zero if anonymous; users cannot see this for posts of someone who has blocked them.
+func (s *PostService) GetMentionedUsers(postID, viewerID uint) ([]models.User, error) {
+	post, err := s.PostRepo.GetPostByID(postID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotBlocked(s.BlockRepo, post.UserID, viewerID); err != nil {
+		return nil, err
+	}
+	return s.MentionRepo.GetMentionedUsers(postID)
+}
+
+// GetMentions returns a page of the newest posts mentioning userID, without muted authors.
+func (s *PostService) GetMentions(userID uint, limit, offset int) ([]models.Post, error) {
+	limit, offset = pageBounds(limit, offset)
+	return s.MentionRepo.GetMentioningPosts(userID, limit, offset)
+}
+
+// resolveMentions returns the IDs of the users content mentions by their current or previous
+// handle. The author and users who have blocked the author are left out.
+func (s *PostService) resolveMentions(authorID uint, content string) ([]uint, error) {
+	userIDs, err := s.UserRepo.ResolveHandles(handle.Mentions(content))
+	if err != nil {
+		return nil, err
+	}
+	var mentionedIDs []uint
+	for _, id := range userIDs {
+		if id != authorID {
+			mentionedIDs = append(mentionedIDs, id)
+		}
+	}
+	return s.BlockRepo.WithoutBlockers(authorID, mentionedIDs)
+}
+
+// GetFeed returns a page of the newest posts by the authors userID follows,
+// without muted authors.
+func (s *PostService) GetFeed(userID uint, limit, offset int) ([]models.Post, error) {
+	limit, offset = pageBounds(limit, offset)
+	return s.PostRepo.GetFeed(userID, limit, offset)
+}
+
+// UpdatePost applies the update to the post if actor is its author with post:edit:own,
+// or has post:edit:any. The mentions are updated to match the new content.
+func (s *PostService) UpdatePost(postID uint, actor *Principal, update PostUpdate, client ClientInfo) (*models.Post, error) {
+	post, err := s.updatePost(postID, actor, update)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditPostUpdate,
+		ActorID:    actor.UserID,
+		TargetType: AuditTargetPost,
+		TargetID:   postID,
+		Client:     client,
+		Err:        err,
+	})
+	return post, err
+}
+
+func (s *PostService) updatePost(postID uint, actor *Principal, update PostUpdate) (*models.Post, error) {
+	post, err := s.PostRepo.GetPostByID(postID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ownPost := post.UserID == actor.UserID
+	if !actor.Can(rbac.PostEditAny) && !(ownPost && actor.Can(rbac.PostEditOwn)) {
+		return nil, ErrForbidden
+	}
+
+	if update.Title != nil {
+		post.Title = *update.Title
+	}
+	if update.Content != nil {
+		post.Content = *update.Content
+	}
+	// Mentions belong to the author, even when a moderator edits the post
+	mentionedIDs, err := s.resolveMentions(post.UserID, post.Content)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.PostRepo.UpdatePost(post, mentionedIDs); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// DeletePost deletes the post if actor is its author with post:delete:own,
+// or has post:delete:any.
+func (s *PostService) DeletePost(postID uint, actor *Principal, client ClientInfo) error {
+	err := s.deletePost(postID, actor)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditPostDelete,
+		ActorID:    actor.UserID,
+		TargetType: AuditTargetPost,
+		TargetID:   postID,
+		Client:     client,
+		Err:        err,
+	})
+	return err
+}
+
+func (s *PostService) deletePost(postID uint, actor *Principal) error {
+	post, err := s.PostRepo.GetPostByID(postID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	ownPost := post.UserID == actor.UserID
+	if !actor.Can(rbac.PostDeleteAny) && !(ownPost && actor.Can(rbac.PostDeleteOwn)) {
+		return ErrForbidden
+	}
 	return s.PostRepo.DeletePost(postID)
 }
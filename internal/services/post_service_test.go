@@ -0,0 +1,64 @@
+package services
+
+import (
+	"blog/internal/models"
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"testing"
+)
+
+func TestPostOwnership(t *testing.T) {
+	e := newTestEnv(t)
+	posts := NewPostService(
+		repositories.NewPostRepository(e.db),
+		e.userRepo,
+		repositories.NewMentionRepository(e.db),
+		repositories.NewBlockRepository(e.db),
+		e.audit,
+	)
+	author := e.createUser(t, "author@example.com", rbac.RoleUser)
+	other := e.createUser(t, "other@example.com", rbac.RoleUser)
+	moderator := e.createUser(t, "moderator@example.com", rbac.RoleModerator)
+
+	const missingPost = 1 << 20
+	tests := []struct {
+		name    string
+		actor   *Principal
+		missing bool
+		want    error
+	}{
+		{"author", &Principal{UserID: author.ID, Role: rbac.RoleUser}, false, nil},
+		{"author with a write token", &Principal{UserID: author.ID, Role: rbac.RoleUser, APITokenID: 1, Scopes: []rbac.Scope{rbac.ScopePostsWrite}}, false, nil},
+		{"author with a read token", &Principal{UserID: author.ID, Role: rbac.RoleUser, APITokenID: 1, Scopes: []rbac.Scope{rbac.ScopePostsRead}}, false, ErrForbidden},
+		{"another user", &Principal{UserID: other.ID, Role: rbac.RoleUser}, false, ErrForbidden},
+		{"moderator", &Principal{UserID: moderator.ID, Role: rbac.RoleModerator}, false, nil},
+		{"missing post as the author", &Principal{UserID: author.ID, Role: rbac.RoleUser}, true, ErrPostNotFound},
+		{"missing post as another user", &Principal{UserID: other.ID, Role: rbac.RoleUser}, true, ErrPostNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := posts.CreatePost(author.ID, CreatePostInput{Title: "Title", Content: "Content"}, testClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			postID := post.ID
+			if tt.missing {
+				postID = missingPost
+			}
+
+			title := "Edited"
+			if _, err := posts.UpdatePost(postID, tt.actor, PostUpdate{Title: &title}, testClient); err != tt.want {
+				t.Errorf("UpdatePost returned %v, want %v", err, tt.want)
+			}
+			if err := posts.DeletePost(postID, tt.actor, testClient); err != tt.want {
+				t.Errorf("DeletePost returned %v, want %v", err, tt.want)
+			}
+
+			var remaining int64
+			e.db.Model(&models.Post{}).Where("id = ?", post.ID).Count(&remaining)
+			if deleted := remaining == 0; deleted != (tt.want == nil) {
+				t.Errorf("post deleted = %t, want %t", deleted, tt.want == nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"blog/internal/handle"
+	"blog/internal/models"
+	"blog/internal/repositories"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrHandleTaken error = errors.New("handle already taken")
+
+// handleRedirectLifetime is how long a previous handle keeps pointing to its user and
+// cannot be taken by anyone else.
+const handleRedirectLifetime = 90 * 24 * time.Hour
+
+// ProfileUpdate holds the profile fields to change; nil fields are left as they are.
+// An empty website or avatar URL clears it.
+type ProfileUpdate struct {
+	Name        *string `json:"name" validate:"required,max=100"`
+	DisplayName *string `json:"display_name" validate:"max=100"`
+	Bio         *string `json:"bio" validate:"max=500"`
+	Website     *string `json:"website" validate:"url,max=255"`
+	AvatarURL   *string `json:"avatar_url" validate:"url,max=255"`
+}
+
+// Profile is a user together with the size of their social graph.
+type Profile struct {
+	User           *models.User
+	FollowersCount int64
+	FollowingCount int64
+}
+
+type ProfileService struct {
+	UserRepo   *repositories.UserRepository
+	FollowRepo *repositories.FollowRepository
+}
+
+func NewProfileService(userRepo *repositories.UserRepository, followRepo *repositories.FollowRepository) *ProfileService {
+	return &ProfileService{UserRepo: userRepo, FollowRepo: followRepo}
+}
+
+// GetUser returns the user without the follow counts.
+func (s *ProfileService) GetUser(userID uint) (*models.User, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+	return user, nil
+}
+
+// GetProfile returns the user whose profile is requested.
+func (s *ProfileService) GetProfile(userID uint) (*Profile, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	followers, following, err := s.FollowRepo.Counts(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{User: user, FollowersCount: followers, FollowingCount: following}, nil
+}
+
+// GetProfileByHandle returns the profile of the user with the handle. If the handle is a
+// previous handle of a user, it instead returns the user's current handle to redirect to.
+func (s *ProfileService) GetProfileByHandle(raw string) (*Profile, string, error) {
+	h := handle.Normalize(raw)
+	user, err := s.UserRepo.GetByHandle(h)
+	if err == nil {
+		profile, err := s.GetProfile(user.ID)
+		return profile, "", err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+
+	redirect, err := s.UserRepo.GetHandleRedirect(h)
+	if err != nil {
+		return nil, "", ErrUNF
+	}
+	user, err = s.UserRepo.GetByID(redirect.UserID)
+	if err != nil || user.Handle == nil {
+		return nil, "", ErrUNF
+	}
+	return nil, *user.Handle, nil
+}
+
+// SetHandle gives the user a new handle. It returns a *handle.Error if the handle is not
+// allowed. The previous handle keeps redirecting to the user for 90 days.
+func (s *ProfileService) SetHandle(userID uint, raw string) (*models.User, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+	h := handle.Normalize(raw)
+	if err := handle.Validate(h); err != nil {
+		return nil, err
+	}
+	if user.Handle != nil && *user.Handle == h {
+		return user, nil
+	}
+
+	err = s.UserRepo.ChangeHandle(user, h, time.Now().Add(handleRedirectLifetime))
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrHandleTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.Handle = &h
+	return user, nil
+}
+
+// UpdateProfile applies the update, which the caller has checked against its validate tags.
+func (s *ProfileService) UpdateProfile(userID uint, update ProfileUpdate) (*models.User, error) {
+	user, err := s.UserRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUNF
+	}
+
+	if update.Name != nil {
+		user.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Bio != nil {
+		user.Bio = strings.TrimSpace(*update.Bio)
+	}
+	if update.Website != nil {
+		user.Website = strings.TrimSpace(*update.Website)
+	}
+	if update.AvatarURL != nil {
+		user.AvatarURL = strings.TrimSpace(*update.AvatarURL)
+	}
+
+	if err := s.UserRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"blog/internal/repositories"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	ThrottleScopeLogin  = "login"
+	ThrottleScopeVerify = "verify"
+	ThrottleScopeMFA    = "mfa"
+	ThrottleScopeResend = "resend"
+)
+
+// throttlePolicy allows threshold failures within window, then locks the key
+// for baseLockout, doubling with every further failure up to maxLockout.
+type throttlePolicy struct {
+	threshold   int
+	window      time.Duration
+	baseLockout time.Duration
+	maxLockout  time.Duration
+}
+
+var (
+	accountThrottlePolicy = throttlePolicy{threshold: 5, window: 24 * time.Hour, baseLockout: time.Minute, maxLockout: time.Hour}
+	// Many users can share an address behind NAT, so IPs get more room than single accounts
+	ipThrottlePolicy = throttlePolicy{threshold: 20, window: time.Hour, baseLockout: time.Minute, maxLockout: time.Hour}
+)
+
+// LockoutError is returned while an account or IP address is locked out.
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return "too many failed attempts, try again later"
+}
+
+// RetryAfter returns the remaining lockout rounded up to whole seconds.
+func (e *LockoutError) RetryAfter() int {
+	return int(math.Ceil(time.Until(e.Until).Seconds()))
+}
+
+// ThrottleService tracks failed authentication attempts per account and per IP address.
+// The counters live in the database so they survive restarts and are shared between instances.
+type ThrottleService struct {
+	ThrottleRepo *repositories.ThrottleRepository
+}
+
+func NewThrottleService(throttleRepo *repositories.ThrottleRepository) *ThrottleService {
+	return &ThrottleService{ThrottleRepo: throttleRepo}
+}
+
+// Check returns a *LockoutError if the account or the IP address is locked out for scope.
+func (s *ThrottleService) Check(scope, account, ip string) error {
+	until, err := s.ThrottleRepo.LockedUntil([]string{accountKey(scope, account), ipKey(scope, ip)})
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		return &LockoutError{Until: *until}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt against both the account and the IP address.
+func (s *ThrottleService) RecordFailure(scope, account, ip string) error {
+	if err := s.recordFailure(accountKey(scope, account), accountThrottlePolicy); err != nil {
+		return err
+	}
+	return s.recordFailure(ipKey(scope, ip), ipThrottlePolicy)
+}
+
+// CheckIP returns a *LockoutError if the IP address is locked out for scope.
+func (s *ThrottleService) CheckIP(scope, ip string) error {
+	until, err := s.ThrottleRepo.LockedUntil([]string{ipKey(scope, ip)})
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		return &LockoutError{Until: *until}
+	}
+	return nil
+}
+
+// RecordIPAttempt counts an attempt against the IP address only. It is for requests that are
+// limited per address whether or not they succeed, where an account counter would reveal
+// which accounts exist.
+func (s *ThrottleService) RecordIPAttempt(scope, ip string) error {
+	return s.recordFailure(ipKey(scope, ip), ipThrottlePolicy)
+}
+
+// Reset clears the account counter after a successful attempt. The IP counter is kept,
+// otherwise one valid account would let an attacker keep guessing others from the same address.
+func (s *ThrottleService) Reset(scope, account string) error {
+	return s.ThrottleRepo.Delete([]string{accountKey(scope, account)})
+}
+
+// UnlockAccount lifts every lockout of the account.
+func (s *ThrottleService) UnlockAccount(account string) error {
+	return s.ThrottleRepo.Delete([]string{
+		accountKey(ThrottleScopeLogin, account),
+		accountKey(ThrottleScopeVerify, account),
+		accountKey(ThrottleScopeMFA, account),
+	})
+}
+
+// UnlockIP lifts every lockout of the IP address.
+func (s *ThrottleService) UnlockIP(ip string) error {
+	return s.ThrottleRepo.Delete([]string{
+		ipKey(ThrottleScopeLogin, ip),
+		ipKey(ThrottleScopeVerify, ip),
+		ipKey(ThrottleScopeMFA, ip),
+		ipKey(ThrottleScopeResend, ip),
+	})
+}
+
+func (s *ThrottleService) recordFailure(key string, policy throttlePolicy) error {
+	throttle, err := s.ThrottleRepo.RecordFailure(key, policy.window)
+	if err != nil {
+		return err
+	}
+	if throttle.Failures < policy.threshold {
+		return nil
+	}
+
+	lockout := policy.baseLockout << min(throttle.Failures-policy.threshold, 16)
+	if lockout > policy.maxLockout {
+		lockout = policy.maxLockout
+	}
+	return s.ThrottleRepo.Lock(key, time.Now().Add(lockout))
+}
+
+func accountKey(scope, account string) string {
+	return fmt.Sprintf("%s:account:%s", scope, strings.ToLower(strings.TrimSpace(account)))
+}
+
+func ipKey(scope, ip string) string {
+	return fmt.Sprintf("%s:ip:%s", scope, ip)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// lockoutAfter records failures for a fresh account and returns how long it is locked out.
+func lockoutAfter(t *testing.T, failures int) time.Duration {
+	t.Helper()
+	e := newTestEnv(t)
+	for i := 0; i < failures; i++ {
+		if err := e.throttle.RecordFailure(ThrottleScopeLogin, "victim@example.com", "198.51.100.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	until, err := e.throttle.ThrottleRepo.LockedUntil([]string{accountKey(ThrottleScopeLogin, "victim@example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until == nil {
+		return 0
+	}
+	return time.Until(*until)
+}
+
+func TestAccountLockoutEscalates(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{10, 32 * time.Minute},
+		{11, time.Hour},
+		{40, time.Hour},
+	}
+	for _, tt := range tests {
+		got := lockoutAfter(t, tt.failures)
+		if got > tt.want || got < tt.want-5*time.Second {
+			t.Errorf("after %d failures locked out for %s, want %s", tt.failures, got.Round(time.Second), tt.want)
+		}
+	}
+}
+
+func TestThrottleCheck(t *testing.T) {
+	e := newTestEnv(t)
+	const account, ip, otherIP = "victim@example.com", "198.51.100.1", "198.51.100.2"
+	for i := 0; i < accountThrottlePolicy.threshold; i++ {
+		if err := e.throttle.Check(ThrottleScopeLogin, account, otherIP); err != nil {
+			t.Fatalf("locked out after %d failures: %v", i, err)
+		}
+		if err := e.throttle.RecordFailure(ThrottleScopeLogin, account, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lockout *LockoutError
+	// The account is locked from every address, but only for the scope that failed
+	if err := e.throttle.Check(ThrottleScopeLogin, "VICTIM@example.com ", otherIP); !errors.As(err, &lockout) {
+		t.Errorf("Check of the locked account returned %v, want a *LockoutError", err)
+	}
+	if err := e.throttle.Check(ThrottleScopeMFA, account, otherIP); err != nil {
+		t.Errorf("Check of another scope returned %v, want nil", err)
+	}
+
+	if err := e.throttle.UnlockAccount(account); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.throttle.Check(ThrottleScopeLogin, account, otherIP); err != nil {
+		t.Errorf("Check after UnlockAccount returned %v, want nil", err)
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+	e := newTestEnv(t)
+	const ip = "198.51.100.1"
+	// Failures spread over many accounts still lock out the address
+	for i := 0; i < ipThrottlePolicy.threshold; i++ {
+		if err := e.throttle.RecordFailure(ThrottleScopeLogin, fmt.Sprintf("user%d@example.com", i), ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lockout *LockoutError
+	if err := e.throttle.Check(ThrottleScopeLogin, "fresh@example.com", ip); !errors.As(err, &lockout) {
+		t.Fatalf("Check from the locked address returned %v, want a *LockoutError", err)
+	}
+	// A success on one account does not clear the address
+	if err := e.throttle.Reset(ThrottleScopeLogin, "user0@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.throttle.CheckIP(ThrottleScopeLogin, ip); !errors.As(err, &lockout) {
+		t.Errorf("CheckIP after Reset returned %v, want a *LockoutError", err)
+	}
+
+	if err := e.throttle.UnlockIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.throttle.CheckIP(ThrottleScopeLogin, ip); err != nil {
+		t.Errorf("CheckIP after UnlockIP returned %v, want nil", err)
+	}
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"blog/config"
+	"blog/internal/models"
+	"blog/internal/rbac"
+	"blog/internal/repositories"
+	"blog/pkg/keyring"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAccessTokenLifetime  = 15 * time.Minute
+	defaultRefreshTokenLifetime = 30 * 24 * time.Hour
+	mfaChallengeLifetime        = 5 * time.Minute
+)
+
+var ErrInvalidToken error = errors.New("invalid token")
+var ErrRefreshTokenReused error = errors.New("refresh token reuse detected")
+var ErrInvalidTokenName error = errors.New("token name must be between 1 and 100 characters")
+var ErrInvalidScope error = errors.New("invalid or missing scope")
+var ErrInvalidTokenLifetime error = errors.New("token lifetime must be between 1 and 365 days")
+var ErrAPITokenNotFound error = errors.New("api token not found")
+var ErrSessionNotFound error = errors.New("session not found")
+var ErrReauthRequired error = errors.New("recent login required")
+
+const (
+	// APITokenPrefix makes personal access tokens recognizable, both for the auth layer
+	// and for secret scanners when one is leaked
+	APITokenPrefix              = "blog_pat_"
+	defaultAPITokenLifetimeDays = 30
+	maxAPITokenLifetimeDays     = 365
+	apiTokenTouchInterval       = time.Minute
+	sessionTouchInterval        = time.Minute
+	// reauthWindow is how long after logging in a session may create API tokens, so a
+	// stolen session cannot be turned into a long-lived credential
+	reauthWindow = 10 * time.Minute
+)
+
+type TokenService struct {
+	TokenRepo    *repositories.TokenRepository
+	Keys         *keyring.Keyring
+	AuditService *AuditService
+}
+
+// TokenPair is returned on login and on every refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// ClientInfo describes where a request came from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// CreatedAPIToken is returned when a personal access token is created.
+// Token is the secret itself and is never shown again.
+type CreatedAPIToken struct {
+	models.APIToken
+	Token string
+}
+
+func NewTokenService(tokenRepo *repositories.TokenRepository, keys *keyring.Keyring, auditService *AuditService) *TokenService {
+	return &TokenService{TokenRepo: tokenRepo, Keys: keys, AuditService: auditService}
+}
+
+// Issue starts a new session for the user on the client and returns its first token pair.
+func (s *TokenService) Issue(userID uint, client ClientInfo) (*TokenPair, error) {
+	sessionID, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	session := &models.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		IP:         client.IP,
+		UserAgent:  truncate(client.UserAgent, 255),
+		LastSeenAt: time.Now(),
+	}
+	if err := s.TokenRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+	return s.issuePair(userID, sessionID)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token can be used
+// only once; presenting an already used token revokes its whole family, since either the
+// client or an attacker holds a stolen copy.
+func (s *TokenService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	stored, err := s.TokenRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if stored.UsedAt != nil {
+		return nil, s.revokeReusedFamily(stored.FamilyID)
+	}
+
+	marked, err := s.TokenRepo.MarkRefreshTokenUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, s.revokeReusedFamily(stored.FamilyID)
+	}
+
+	if err := s.touchSession(stored.UserID, stored.FamilyID, client); err != nil {
+		return nil, err
+	}
+	return s.issuePair(stored.UserID, stored.FamilyID)
+}
+
+// Revoke signs out the session the given refresh token belongs to.
+func (s *TokenService) Revoke(refreshToken string, client ClientInfo) error {
+	stored, err := s.TokenRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	err = s.TokenRepo.RevokeFamily(stored.FamilyID)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditUserLogout,
+		ActorID:    stored.UserID,
+		TargetType: AuditTargetUser,
+		TargetID:   stored.UserID,
+		Client:     client,
+		Err:        err,
+	})
+	return err
+}
+
+// RevokeAll signs the user out of every session and revokes their API tokens.
+func (s *TokenService) RevokeAll(userID uint) error {
+	return s.TokenRepo.RevokeAllForUser(userID)
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+func (s *TokenService) ListSessions(userID uint) ([]models.Session, error) {
+	return s.TokenRepo.ListSessions(userID)
+}
+
+// RevokeSession signs out one of the user's sessions. Its access tokens stop working immediately.
+func (s *TokenService) RevokeSession(userID uint, sessionID string) error {
+	revoked, err := s.TokenRepo.RevokeSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// ParseAccessToken validates an access token and returns the user and session it was issued for.
+// Tokens of revoked sessions are rejected.
+func (s *TokenService) ParseAccessToken(tokenStr string, client ClientInfo) (uint, string, error) {
+	userID, claims, err := s.parseJWT(tokenStr, "access")
+	if err != nil {
+		return 0, "", err
+	}
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" {
+		return 0, "", ErrInvalidToken
+	}
+
+	session, err := s.TokenRepo.GetSession(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return 0, "", ErrInvalidToken
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.TokenRepo.TouchSession(session.ID, client.IP, truncate(client.UserAgent, 255), time.Now()); err != nil {
+			return 0, "", err
+		}
+	}
+	return userID, sessionID, nil
+}
+
+// IssueMFAChallenge returns a short-lived token proving that the user passed the password step
+// of a login. It is exchanged for a token pair once the second factor is verified.
+func (s *TokenService) IssueMFAChallenge(userID uint) (string, error) {
+	return s.Keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"typ":     "mfa",
+		"exp":     time.Now().Add(mfaChallengeLifetime).Unix(),
+	})
+}
+
+// ParseMFAChallenge validates a token from IssueMFAChallenge and returns its user ID.
+func (s *TokenService) ParseMFAChallenge(tokenStr string) (uint, error) {
+	userID, _, err := s.parseJWT(tokenStr, "mfa")
+	return userID, err
+}
+
+// IssueMagicLink returns a signed login link token for the user. Its "jti" identifies the
+// server-side record that makes the link single-use.
+func (s *TokenService) IssueMagicLink(userID uint, tokenID string, expiresAt time.Time) (string, error) {
+	return s.Keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"jti":     tokenID,
+		"typ":     "magic",
+		"exp":     expiresAt.Unix(),
+	})
+}
+
+// ParseMagicLink validates a token from IssueMagicLink and returns its user ID and token ID.
+func (s *TokenService) ParseMagicLink(tokenStr string) (uint, string, error) {
+	userID, claims, err := s.parseJWT(tokenStr, "magic")
+	if err != nil {
+		return 0, "", err
+	}
+	tokenID, _ := claims["jti"].(string)
+	if tokenID == "" {
+		return 0, "", ErrInvalidToken
+	}
+	return userID, tokenID, nil
+}
+
+func (s *TokenService) parseJWT(tokenStr, typ string) (uint, jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := s.Keys.Parse(tokenStr, claims)
+	if err != nil || !token.Valid {
+		return 0, nil, ErrInvalidToken
+	}
+
+	if claims["typ"] != typ {
+		return 0, nil, ErrInvalidToken
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID <= 0 {
+		return 0, nil, ErrInvalidToken
+	}
+	return uint(userID), claims, nil
+}
+
+// CreateAPIToken creates a personal access token for the user. It may use the owner's permissions
+// only as far as scopes allow, and expires after lifetimeDays (30 when zero). The session it is
+// created from must have logged in within the last 10 minutes.
+func (s *TokenService) CreateAPIToken(userID uint, sessionID string, name string, scopes []string, lifetimeDays int, client ClientInfo) (*CreatedAPIToken, error) {
+	token, err := s.createAPIToken(userID, sessionID, name, scopes, lifetimeDays)
+	var tokenID uint
+	if token != nil {
+		tokenID = token.ID
+	}
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditAPITokenCreate,
+		ActorID:    userID,
+		TargetType: AuditTargetAPIToken,
+		TargetID:   tokenID,
+		Client:     client,
+		Err:        err,
+	})
+	return token, err
+}
+
+func (s *TokenService) createAPIToken(userID uint, sessionID string, name string, scopes []string, lifetimeDays int) (*CreatedAPIToken, error) {
+	session, err := s.TokenRepo.GetSession(sessionID)
+	if err != nil || session.UserID != userID || time.Since(session.CreatedAt) > reauthWindow {
+		return nil, ErrReauthRequired
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, ErrInvalidTokenName
+	}
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !rbac.ValidScope(rbac.Scope(scope)) {
+			return nil, ErrInvalidScope
+		}
+	}
+	if lifetimeDays == 0 {
+		lifetimeDays = defaultAPITokenLifetimeDays
+	}
+	if lifetimeDays < 0 || lifetimeDays > maxAPITokenLifetimeDays {
+		return nil, ErrInvalidTokenLifetime
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	token := APITokenPrefix + secret
+	apiToken := models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(APITokenPrefix)+4],
+		TokenHash: hashToken(token),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().AddDate(0, 0, lifetimeDays),
+	}
+	if err := s.TokenRepo.CreateAPIToken(&apiToken); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIToken{APIToken: apiToken, Token: token}, nil
+}
+
+func (s *TokenService) ListAPITokens(userID uint) ([]models.APIToken, error) {
+	return s.TokenRepo.ListAPITokens(userID)
+}
+
+func (s *TokenService) RevokeAPIToken(userID, tokenID uint, client ClientInfo) error {
+	err := s.revokeAPIToken(userID, tokenID)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditAPITokenRevoke,
+		ActorID:    userID,
+		TargetType: AuditTargetAPIToken,
+		TargetID:   tokenID,
+		Client:     client,
+		Err:        err,
+	})
+	return err
+}
+
+func (s *TokenService) revokeAPIToken(userID, tokenID uint) error {
+	revoked, err := s.TokenRepo.RevokeAPIToken(userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// ParseAPIToken returns the stored personal access token if it is valid,
+// and records when it was last used.
+func (s *TokenService) ParseAPIToken(token string) (*models.APIToken, error) {
+	apiToken, err := s.TokenRepo.GetAPITokenByHash(hashToken(token))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if apiToken.RevokedAt != nil || now.After(apiToken.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > apiTokenTouchInterval {
+		if err := s.TokenRepo.TouchAPIToken(apiToken.ID, now); err != nil {
+			return nil, err
+		}
+		apiToken.LastUsedAt = &now
+	}
+	return apiToken, nil
+}
+
+// touchSession records the refresh on the session. Sessions created before sessions were
+// tracked have no row yet; it is created on their first refresh.
+func (s *TokenService) touchSession(userID uint, sessionID string, client ClientInfo) error {
+	_, err := s.TokenRepo.GetSession(sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.TokenRepo.CreateSession(&models.Session{
+			ID:         sessionID,
+			UserID:     userID,
+			IP:         client.IP,
+			UserAgent:  truncate(client.UserAgent, 255),
+			LastSeenAt: time.Now(),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return s.TokenRepo.TouchSession(sessionID, client.IP, truncate(client.UserAgent, 255), time.Now())
+}
+
+func (s *TokenService) issuePair(userID uint, sessionID string) (*TokenPair, error) {
+	accessLifetime := accessTokenLifetime()
+	accessToken, err := s.generateJWT(userID, sessionID, accessLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	stored := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenLifetime()),
+	}
+	if err := s.TokenRepo.CreateRefreshToken(stored); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessLifetime.Seconds()),
+	}, nil
+}
+
+func (s *TokenService) revokeReusedFamily(familyID string) error {
+	if err := s.TokenRepo.RevokeFamily(familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func (s *TokenService) generateJWT(userID uint, sessionID string, lifetime time.Duration) (string, error) {
+	return s.Keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"sid":     sessionID,
+		"typ":     "access",
+		"exp":     time.Now().Add(lifetime).Unix(),
+	})
+}
+
+func accessTokenLifetime() time.Duration {
+	if minutes := config.AppConfig.JWT.TokenLifetimeMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultAccessTokenLifetime
+}
+
+func refreshTokenLifetime() time.Duration {
+	if minutes := config.AppConfig.JWT.RefreshTokenLifetimeMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultRefreshTokenLifetime
+}
+
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
+
+// hashToken is used for every random secret kept in the database: the tokens carry
+// enough entropy that a fast hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
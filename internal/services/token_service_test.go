@@ -0,0 +1,101 @@
+package services
+
+import (
+	"blog/internal/rbac"
+	"testing"
+)
+
+var testClient = ClientInfo{IP: "198.51.100.1", UserAgent: "test-agent"}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	tests := []struct {
+		name      string
+		rotations int
+		replay    int
+	}{
+		{"first token replayed after one refresh", 1, 0},
+		{"first token replayed after several refreshes", 3, 0},
+		{"middle token replayed", 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			user := e.createUser(t, "reuse@example.com", rbac.RoleUser)
+
+			pair, err := e.tokens.Issue(user.ID, testClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := e.tokens.Issue(user.ID, testClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			issued := []string{pair.RefreshToken}
+			for i := 0; i < tt.rotations; i++ {
+				if pair, err = e.tokens.Refresh(pair.RefreshToken, testClient); err != nil {
+					t.Fatalf("refresh %d: %s", i+1, err)
+				}
+				issued = append(issued, pair.RefreshToken)
+			}
+
+			if _, err := e.tokens.Refresh(issued[tt.replay], testClient); err != ErrRefreshTokenReused {
+				t.Fatalf("replayed refresh token returned %v, want %v", err, ErrRefreshTokenReused)
+			}
+			// The latest token was never used, but whoever holds it may be the attacker
+			if _, err := e.tokens.Refresh(pair.RefreshToken, testClient); err != ErrInvalidToken {
+				t.Errorf("latest refresh token of the family returned %v, want %v", err, ErrInvalidToken)
+			}
+			// Other sessions of the user are not affected
+			if _, err := e.tokens.Refresh(other.RefreshToken, testClient); err != nil {
+				t.Errorf("refresh token of another session returned %v, want it to still work", err)
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenRejectsRevokedSessions(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(e *testEnv, userID uint, sessionID string, pair *TokenPair) error
+	}{
+		{"logout", func(e *testEnv, _ uint, _ string, pair *TokenPair) error {
+			return e.tokens.Revoke(pair.RefreshToken, testClient)
+		}},
+		{"session revoked", func(e *testEnv, userID uint, sessionID string, _ *TokenPair) error {
+			return e.tokens.RevokeSession(userID, sessionID)
+		}},
+		{"all sessions revoked", func(e *testEnv, userID uint, _ string, _ *TokenPair) error {
+			return e.tokens.RevokeAll(userID)
+		}},
+		{"refresh token reused", func(e *testEnv, _ uint, _ string, pair *TokenPair) error {
+			if _, err := e.tokens.Refresh(pair.RefreshToken, testClient); err != nil {
+				return err
+			}
+			if _, err := e.tokens.Refresh(pair.RefreshToken, testClient); err != ErrRefreshTokenReused {
+				return err
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			user := e.createUser(t, "revoked@example.com", rbac.RoleUser)
+			pair, err := e.tokens.Issue(user.ID, testClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			userID, sessionID, err := e.tokens.ParseAccessToken(pair.AccessToken, testClient)
+			if err != nil || userID != user.ID {
+				t.Fatalf("ParseAccessToken before revoking = %d, %v", userID, err)
+			}
+
+			if err := tt.revoke(e, user.ID, sessionID, pair); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := e.tokens.ParseAccessToken(pair.AccessToken, testClient); err != ErrInvalidToken {
+				t.Errorf("access token of the revoked session returned %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
@@ -2,88 +2,341 @@ package services
 
 import (
 	"blog/config"
+	"blog/internal/handle"
 	"blog/internal/models"
+	"blog/internal/rbac"
 	"blog/internal/repositories"
+	"blog/pkg/password"
 	"crypto/rand"
+	"crypto/subtle"
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
-	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/gomail.v2"
+	"gorm.io/gorm"
 )
 
 type UserService struct {
-	UserRepo *repositories.UserRepository
+	UserRepo        *repositories.UserRepository
+	TokenService    *TokenService
+	ThrottleService *ThrottleService
+	Hasher          *password.Hasher
+	PasswordPolicy  *password.Policy
+	AuditService    *AuditService
 }
 
 var ErrUNF error = errors.New("user not found")
 var ErrIVC error = errors.New("invalid verification code")
+var ErrInvalidCredentials error = errors.New("invalid credentials")
+var ErrInvalidResetToken error = errors.New("invalid or expired reset token")
+var ErrEmptyPassword error = errors.New("password must not be empty")
+var ErrEmailNotVerified error = errors.New("email not verified")
+var ErrVerificationExpired error = errors.New("verification code expired")
+var ErrTooManyAttempts error = errors.New("too many verification attempts")
+var ErrResendTooSoon error = errors.New("verification email was sent recently")
+var ErrUserBanned error = errors.New("account is banned")
 
-func NewUserService(userRepo *repositories.UserRepository) *UserService {
-	return &UserService{UserRepo: userRepo}
+// LoginResult is either a token pair or, for users with two-factor authentication,
+// a challenge token to pass to MFAService.CompleteLogin along with the second factor.
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
 }
 
-func (s *UserService) Login(email, password string) (string, error) {
+// Principal is the authenticated caller of a request. Requests made with a personal
+// access token carry its ID and scopes; for interactive sessions APITokenID is zero.
+type Principal struct {
+	UserID     uint
+	Role       string
+	SessionID  string
+	APITokenID uint
+	Scopes     []rbac.Scope
+}
+
+// Can reports whether the principal's role grants permission and, for API tokens,
+// whether the token has the scope the permission requires.
+func (p *Principal) Can(permission rbac.Permission) bool {
+	if !rbac.Has(p.Role, permission) {
+		return false
+	}
+	if p.APITokenID == 0 {
+		return true
+	}
+
+	required, ok := rbac.ScopeFor(permission)
+	if !ok {
+		return false
+	}
+	for _, scope := range p.Scopes {
+		if scope == required {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	passwordResetTokenLifetime = 30 * time.Minute
+	verificationCodeLifetime   = 24 * time.Hour
+	verificationResendCooldown = time.Minute
+	maxVerificationAttempts    = 5
+)
+
+func NewUserService(userRepo *repositories.UserRepository, tokenService *TokenService, throttleService *ThrottleService, hasher *password.Hasher, passwordPolicy *password.Policy, auditService *AuditService) *UserService {
+	return &UserService{UserRepo: userRepo, TokenService: tokenService, ThrottleService: throttleService, Hasher: hasher, PasswordPolicy: passwordPolicy, AuditService: auditService}
+}
+
+// Login checks the credentials coming from ip. Repeated failures lock out the account
+// and the address; while locked out it returns a *LockoutError without checking the password.
+// Users with two-factor authentication get an MFA challenge instead of tokens.
+func (s *UserService) Login(email, password string, client ClientInfo) (*LoginResult, error) {
+	ip := client.IP
+	if err := s.ThrottleService.Check(ThrottleScopeLogin, email, ip); err != nil {
+		s.auditLogin(0, email, client, err)
+		return nil, err
+	}
+
 	user, err := s.UserRepo.GetByEmail(email)
 	if err != nil {
-		return "", errors.New("user not found")
+		s.auditLogin(0, email, client, ErrUNF)
+		return nil, s.loginFailed(email, ip, ErrUNF)
+	}
+
+	if err := s.Hasher.Verify(password, user.Password); err != nil {
+		s.auditLogin(user.ID, email, client, ErrInvalidCredentials)
+		return nil, s.loginFailed(email, ip, ErrInvalidCredentials)
+	}
+	if s.Hasher.NeedsRehash(user.Password) {
+		s.rehashPassword(user, password)
+	}
+	if err := s.ThrottleService.Reset(ThrottleScopeLogin, email); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// completeLogin applies the checks every login method shares once the user has been identified.
+// A login that continues with an MFA challenge is audited once the challenge is completed.
+func (s *UserService) completeLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	if err := s.checkLoginAllowed(user, client); err != nil {
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("invalid credentials")
+	if user.TOTPEnabled {
+		mfaToken, err := s.TokenService.IssueMFAChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
 	}
 
-	token, err := generateJWT(user.ID)
+	tokens, err := s.TokenService.Issue(user.ID, client)
+	s.auditLogin(user.ID, user.Email, client, err)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return token, nil
+	return &LoginResult{TokenPair: tokens}, nil
 }
 
-func generateJWT(userID uint) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+// checkLoginAllowed returns ErrUserBanned or ErrEmailNotVerified, and audits the failed login,
+// if the user may not log in yet.
+func (s *UserService) checkLoginAllowed(user *models.User, client ClientInfo) error {
+	var err error
+	switch {
+	case user.BannedAt != nil:
+		err = ErrUserBanned
+	case !user.IsVerified:
+		err = ErrEmailNotVerified
+	default:
+		return nil
+	}
+	s.auditLogin(user.ID, user.Email, client, err)
+	return err
+}
 
-	secret := config.AppConfig.JWT.SecretKey
-	return token.SignedString([]byte(secret))
+func (s *UserService) auditLogin(userID uint, email string, client ClientInfo, err error) {
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditUserLogin,
+		ActorID:    userID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Identifier: email,
+		Client:     client,
+		Err:        err,
+	})
 }
 
-func (s *UserService) RegisterUser(user *models.User) error {
-	exists, err := s.UserRepo.EmailExists(user.Email)
+// rehashPassword upgrades the stored hash to the configured algorithm and parameters while the
+// plaintext password is at hand. A failure only delays the upgrade, so it does not fail the login.
+func (s *UserService) rehashPassword(user *models.User, password string) {
+	hashedPass, err := s.Hasher.Hash(password)
+	if err == nil {
+		user.Password = hashedPass
+		err = s.UserRepo.Update(user)
+	}
 	if err != nil {
+		log.Printf("Failed to rehash password of user %d, %s", user.ID, err)
+	}
+}
+
+func (s *UserService) loginFailed(email, ip string, cause error) error {
+	if err := s.ThrottleService.RecordFailure(ThrottleScopeLogin, email, ip); err != nil {
 		return err
 	}
+	return cause
+}
+
+// Authenticate resolves an access token or a personal access token to the principal it was
+// issued for. The user is loaded on every call, so bans and role changes apply without waiting
+// for tokens to expire.
+func (s *UserService) Authenticate(token string, client ClientInfo) (*Principal, error) {
+	principal := &Principal{}
+	if strings.HasPrefix(token, APITokenPrefix) {
+		apiToken, err := s.TokenService.ParseAPIToken(token)
+		if err != nil {
+			return nil, err
+		}
+		principal.UserID = apiToken.UserID
+		principal.APITokenID = apiToken.ID
+		for _, scope := range strings.Fields(apiToken.Scopes) {
+			principal.Scopes = append(principal.Scopes, rbac.Scope(scope))
+		}
+	} else {
+		userID, sessionID, err := s.TokenService.ParseAccessToken(token, client)
+		if err != nil {
+			return nil, err
+		}
+		principal.UserID = userID
+		principal.SessionID = sessionID
+	}
+
+	user, err := s.UserRepo.GetByID(principal.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+	principal.Role = user.Role
+	return principal, nil
+}
+
+// RegisterInput is what a client may set when registering. Everything else on the user,
+// such as the role or the verification state, is set here.
+type RegisterInput struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
+	// Handle is optional; users without one can pick it later.
+	Handle string `json:"handle" validate:"max=31"`
+}
+
+func (s *UserService) RegisterUser(input RegisterInput, client ClientInfo) error {
+	var userID uint
+	user, err := s.registerUser(input)
+	if user != nil {
+		userID = user.ID
+	}
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditUserRegister,
+		ActorID:    userID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+	return err
+}
+
+func (s *UserService) registerUser(input RegisterInput) (*models.User, error) {
+	exists, err := s.UserRepo.EmailExists(input.Email)
+	if err != nil {
+		return nil, err
+	}
 	if exists {
-		return errors.New("email already exists")
+		return nil, ErrEmailTaken
+	}
+	var userHandle *string
+	if input.Handle != "" {
+		h := handle.Normalize(input.Handle)
+		if err := handle.Validate(h); err != nil {
+			return nil, err
+		}
+		available, err := s.UserRepo.HandleAvailable(h, 0)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			return nil, ErrHandleTaken
+		}
+		userHandle = &h
+	}
+	if err := s.PasswordPolicy.Check(input.Password, input.Name, input.Email); err != nil {
+		return nil, err
 	}
 
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPass, err := s.Hasher.Hash(input.Password)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	user.Password = string(hashedPass)
 
-	verificationCode, err := generateVerificationCode()
+	user := &models.User{
+		Name:       input.Name,
+		Email:      input.Email,
+		Handle:     userHandle,
+		Password:   hashedPass,
+		IsVerified: false,
+		Role:       rbac.RoleUser,
+	}
+	verificationCode, err := setVerificationCode(user)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	user.VerificationCode = verificationCode
-	user.IsVerified = false
 
 	if err := sendVerificationEmail(user.Email, verificationCode); err != nil {
-		return errors.New("failed to send verification email" + err.Error())
+		return nil, errors.New("failed to send verification email" + err.Error())
 	}
 
 	if err := s.UserRepo.Create(user); err != nil {
-		return err
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, s.takenError(input.Email)
+		}
+		return nil, err
 	}
 
-	return nil
+	return user, nil
+}
+
+// takenError tells whether a concurrent registration took the email or the handle,
+// as the duplicate key error does not say which.
+func (s *UserService) takenError(email string) error {
+	if exists, err := s.UserRepo.EmailExists(email); err != nil || exists {
+		return ErrEmailTaken
+	}
+	return ErrHandleTaken
+}
+
+// setVerificationCode issues a fresh code for the user and returns it in plaintext;
+// only its hash is kept on the user.
+func setVerificationCode(user *models.User) (string, error) {
+	code, err := generateVerificationCode()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(verificationCodeLifetime)
+	user.VerificationCode = hashToken(code)
+	user.VerificationExpiresAt = &expiresAt
+	user.VerificationSentAt = &now
+	user.VerificationAttempts = 0
+	return code, nil
 }
 
 func generateVerificationCode() (string, error) {
@@ -95,6 +348,16 @@ func generateVerificationCode() (string, error) {
 }
 
 func sendVerificationEmail(email, code string) error {
+	return sendEmail(email, "Email Verification", "Your verification code is: "+code)
+}
+
+func sendPasswordResetEmail(email, token string) error {
+	return sendEmail(email, "Password Reset",
+		"Use this code to reset your password: "+token+"\n\n"+
+			"It expires in 30 minutes. If you did not request a password reset, you can ignore this email.")
+}
+
+func sendEmail(to, subject, body string) error {
 	d := gomail.NewDialer(
 		config.AppConfig.Email.SMTPServer,
 		config.AppConfig.Email.SMTPPort,
@@ -104,25 +367,206 @@ func sendVerificationEmail(email, code string) error {
 	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
 
 	message := gomail.NewMessage()
-	message.SetHeader("From", "thunderleo@mail.ru")
-	message.SetHeader("To", email)
-	message.SetHeader("Subject", "Email Verification")
-	message.SetBody("text/plain", "Your verification code is: "+code)
+	message.SetHeader("From", config.AppConfig.Email.From)
+	message.SetHeader("To", to)
+	message.SetHeader("Subject", subject)
+	message.SetBody("text/plain", body)
 
 	return d.DialAndSend(message)
 }
 
-func (s *UserService) VerifyEmail(email, code string) error {
+func (s *UserService) VerifyEmail(email, code string, client ClientInfo) error {
+	userID, err := s.verifyEmail(email, code, client.IP)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditUserVerifyEmail,
+		ActorID:    userID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+	return err
+}
+
+// verifyEmail returns the ID of the user the email belongs to, or zero if it is unknown.
+func (s *UserService) verifyEmail(email, code, ip string) (uint, error) {
+	if err := s.ThrottleService.Check(ThrottleScopeVerify, email, ip); err != nil {
+		return 0, err
+	}
+
 	user, err := s.UserRepo.GetByEmail(email)
 	if err != nil {
-		return ErrUNF
+		return 0, s.verifyFailed(email, ip, ErrUNF)
+	}
+	if user.IsVerified {
+		return user.ID, nil
 	}
 
-	if user.VerificationCode != code {
-		return ErrIVC
+	if user.VerificationAttempts >= maxVerificationAttempts {
+		return user.ID, ErrTooManyAttempts
+	}
+	if user.VerificationExpiresAt == nil || time.Now().After(*user.VerificationExpiresAt) {
+		return user.ID, ErrVerificationExpired
+	}
+
+	if subtle.ConstantTimeCompare([]byte(user.VerificationCode), []byte(hashToken(code))) != 1 {
+		user.VerificationAttempts++
+		if err := s.UserRepo.Update(user); err != nil {
+			return user.ID, err
+		}
+		return user.ID, s.verifyFailed(email, ip, ErrIVC)
 	}
 
 	user.IsVerified = true
 	user.VerificationCode = ""
-	return s.UserRepo.Update(user)
+	user.VerificationExpiresAt = nil
+	user.VerificationAttempts = 0
+	if err := s.UserRepo.Update(user); err != nil {
+		return user.ID, err
+	}
+	return user.ID, s.ThrottleService.Reset(ThrottleScopeVerify, email)
+}
+
+func (s *UserService) verifyFailed(email, ip string, cause error) error {
+	if err := s.ThrottleService.RecordFailure(ThrottleScopeVerify, email, ip); err != nil {
+		return err
+	}
+	return cause
+}
+
+// ResendVerification emails a new verification code, replacing the previous one. Unknown and
+// already verified emails and repeated requests within the cooldown are ignored, so the
+// response does not reveal them. Requests are limited per IP address, returning a *LockoutError.
+func (s *UserService) ResendVerification(email, ip string) error {
+	if err := s.ThrottleService.CheckIP(ThrottleScopeResend, ip); err != nil {
+		return err
+	}
+	if err := s.ThrottleService.RecordIPAttempt(ThrottleScopeResend, ip); err != nil {
+		return err
+	}
+
+	user, err := s.UserRepo.GetByEmail(email)
+	if err != nil || user.IsVerified {
+		return nil
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendCooldown {
+		return nil
+	}
+
+	code, err := setVerificationCode(user)
+	if err != nil {
+		return err
+	}
+	if err := s.UserRepo.Update(user); err != nil {
+		return err
+	}
+
+	// As with password resets, sending in the background keeps the response the same either way
+	go func() {
+		if err := sendVerificationEmail(user.Email, code); err != nil {
+			log.Printf("Failed to send verification email to user %d, %s", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// RequestPasswordReset emails a single-use reset code to the user. It behaves the same
+// whether or not the email is registered, so callers cannot use it to probe for accounts.
+func (s *UserService) RequestPasswordReset(email string) error {
+	user, err := s.UserRepo.GetByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Creating and sending the code in the background keeps the response time independent
+	// of whether the account exists
+	go func() {
+		if err := s.sendPasswordReset(user); err != nil {
+			log.Printf("Failed to send password reset email to user %d, %s", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendPasswordReset replaces the user's reset codes with a new one and emails it.
+func (s *UserService) sendPasswordReset(user *models.User) error {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := s.TokenService.TokenRepo.InvalidatePasswordResetTokens(user.ID); err != nil {
+		return err
+	}
+	resetToken := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTokenLifetime),
+	}
+	if err := s.TokenService.TokenRepo.CreatePasswordResetToken(resetToken); err != nil {
+		return err
+	}
+	return sendPasswordResetEmail(user.Email, token)
+}
+
+// ResetPassword sets a new password using a code from RequestPasswordReset
+// and signs the user out everywhere.
+func (s *UserService) ResetPassword(token, newPassword string, client ClientInfo) error {
+	userID, err := s.resetPassword(token, newPassword)
+	s.AuditService.Record(AuditEntry{
+		Action:     AuditUserPasswordReset,
+		ActorID:    userID,
+		TargetType: AuditTargetUser,
+		TargetID:   userID,
+		Client:     client,
+		Err:        err,
+	})
+	return err
+}
+
+// resetPassword returns the ID of the user the code belongs to, or zero if it is invalid.
+func (s *UserService) resetPassword(token, newPassword string) (uint, error) {
+	if newPassword == "" {
+		return 0, ErrEmptyPassword
+	}
+
+	resetToken, err := s.TokenService.TokenRepo.GetPasswordResetTokenByHash(hashToken(token))
+	if err != nil {
+		return 0, ErrInvalidResetToken
+	}
+	if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return 0, ErrInvalidResetToken
+	}
+	user, err := s.UserRepo.GetByID(resetToken.UserID)
+	if err != nil {
+		return 0, ErrInvalidResetToken
+	}
+	// Checked before the code is used up, so the user can try another password
+	if err := s.PasswordPolicy.Check(newPassword, user.Name, user.Email); err != nil {
+		return user.ID, err
+	}
+
+	consumed, err := s.TokenService.TokenRepo.MarkPasswordResetTokenUsed(resetToken.ID)
+	if err != nil {
+		return user.ID, err
+	}
+	if !consumed {
+		return user.ID, ErrInvalidResetToken
+	}
+
+	hashedPass, err := s.Hasher.Hash(newPassword)
+	if err != nil {
+		return user.ID, err
+	}
+	user.Password = hashedPass
+	if err := s.UserRepo.Update(user); err != nil {
+		return user.ID, err
+	}
+
+	if err := s.TokenService.TokenRepo.InvalidatePasswordResetTokens(user.ID); err != nil {
+		return user.ID, err
+	}
+	return user.ID, s.TokenService.RevokeAll(user.ID)
 }
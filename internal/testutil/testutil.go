@@ -0,0 +1,47 @@
+// Package testutil provides the database and signing keys that service and handler tests run against.
+package testutil
+
+import (
+	"blog/internal/models"
+	"blog/pkg/keyring"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// OpenDB returns a migrated in-memory SQLite database that is closed when the test ends.
+// It is opened like the production database, so unique violations are gorm.ErrDuplicatedKey.
+func OpenDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to ":memory:" opens a new, empty database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Keyring returns a keyring with a single HS256 signing key.
+func Keyring(t testing.TB) *keyring.Keyring {
+	t.Helper()
+	keys, err := keyring.New("", []keyring.KeyConfig{{ID: "test", Algorithm: "HS256", Secret: "test-secret-for-signing-tokens"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
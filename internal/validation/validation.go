@@ -0,0 +1,145 @@
+// Package validation checks request structs against rules declared in `validate` struct tags,
+// for example `validate:"required,max=255"`. Fields are reported by their JSON names.
+//
+// Supported rules:
+//
+//	required    the value must not be empty; strings must not be blank
+//	max=N       strings must have at most N characters
+//	maxbytes=N  strings must be at most N bytes long in UTF-8, for limits such as bcrypt's
+//	email       strings must be a plain email address
+//	url         strings must be an absolute http or https URL
+//
+// Apart from required, rules are skipped for empty values. Pointer fields are skipped when nil.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes one field that failed a rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of every failed field, in declaration order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Struct validates v, a struct or a pointer to one, and returns Errors if any rule fails.
+func Struct(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		rules, ok := field.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+
+		if fieldErr, failed := check(jsonName(field), fieldValue, rules); failed {
+			errs = append(errs, fieldErr)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check applies the rules to the value and returns the first failure.
+func check(name string, value reflect.Value, rules string) (FieldError, bool) {
+	str := ""
+	if value.Kind() == reflect.String {
+		str = value.String()
+	}
+	empty := value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(str) == "")
+
+	for _, rule := range strings.Split(rules, ",") {
+		ruleName, param, _ := strings.Cut(rule, "=")
+		if ruleName != "required" && empty {
+			continue
+		}
+
+		switch ruleName {
+		case "required":
+			if empty {
+				return FieldError{name, "required", "is required"}, true
+			}
+		case "max":
+			if n := mustAtoi(param); utf8.RuneCountInString(str) > n {
+				return FieldError{name, "too_long", fmt.Sprintf("must be at most %d characters", n)}, true
+			}
+		case "maxbytes":
+			if n := mustAtoi(param); len(str) > n {
+				return FieldError{name, "too_long", fmt.Sprintf("must be at most %d bytes", n)}, true
+			}
+		case "email":
+			if !validEmail(str) {
+				return FieldError{name, "invalid_email", "must be a valid email address"}, true
+			}
+		case "url":
+			if !validURL(str) {
+				return FieldError{name, "invalid_url", "must be an http or https URL"}, true
+			}
+		default:
+			panic("validation: unknown rule " + ruleName)
+		}
+	}
+	return FieldError{}, false
+}
+
+func validEmail(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// mustAtoi parses a rule parameter; a malformed one is a programming error in a struct tag.
+func mustAtoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		panic("validation: invalid rule parameter " + s)
+	}
+	return n
+}
@@ -22,7 +22,10 @@ func Connect(config DBConfig) (*gorm.DB, error) {
 		config.Host, config.User, config.Password, config.Name, config.Port, config.SSLMode,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Unique violations are reported as gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,57 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+	kr := newKeyring(t, "ed",
+		KeyConfig{ID: "hs", Algorithm: "HS256", Secret: "a-long-test-secret"},
+		KeyConfig{ID: "rs", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate},
+		KeyConfig{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivate},
+		KeyConfig{ID: "old", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic, Status: StatusRetired},
+	)
+
+	set := kr.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want the RSA and Ed25519 keys only: %+v", len(set.Keys), set.Keys)
+	}
+
+	rs, ed := set.Keys[0], set.Keys[1]
+	if rs.Kid != "rs" || rs.Kty != "RSA" || rs.Alg != "RS256" || rs.Use != "sig" {
+		t.Errorf("RSA key = %+v", rs)
+	}
+	rsaPublic := kr.keys["rs"].verifyKey.(*rsa.PublicKey)
+	if n, err := base64.RawURLEncoding.DecodeString(rs.N); err != nil || new(big.Int).SetBytes(n).Cmp(rsaPublic.N) != 0 {
+		t.Errorf("RSA modulus does not match the key: %v", err)
+	}
+	if e, err := base64.RawURLEncoding.DecodeString(rs.E); err != nil || new(big.Int).SetBytes(e).Int64() != int64(rsaPublic.E) {
+		t.Errorf("RSA exponent %q does not match the key: %v", rs.E, err)
+	}
+
+	if ed.Kid != "ed" || ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || ed.Use != "sig" {
+		t.Errorf("Ed25519 key = %+v", ed)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	if err != nil || !ed25519.PublicKey(x).Equal(kr.keys["ed"].verifyKey) {
+		t.Errorf("Ed25519 x does not match the key: %v", err)
+	}
+
+	for _, key := range set.Keys {
+		if key.Kid == "hs" || key.Kid == "old" {
+			t.Errorf("JWKS publishes key %q", key.Kid)
+		}
+	}
+}
+
+func TestJWKSEmpty(t *testing.T) {
+	kr := newKeyring(t, "hs", KeyConfig{ID: "hs", Algorithm: "HS256", Secret: "secret"})
+	if set := kr.JWKS(); set.Keys == nil || len(set.Keys) != 0 {
+		t.Errorf("JWKS of an HMAC-only keyring = %+v, want an empty key list", set)
+	}
+}
@@ -0,0 +1,210 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	StatusActive  = "active"
+	StatusRetired = "retired"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeyConfig describes one signing key. HS256 keys use Secret; RS256 and EdDSA keys are read
+// from PEM files. A key with only a public key file can verify tokens but never sign them.
+type KeyConfig struct {
+	ID             string
+	Algorithm      string
+	Status         string
+	Secret         string
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+type Key struct {
+	ID        string
+	Algorithm string
+	Status    string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Keyring holds every configured key. Tokens are signed with a single key and carry its ID
+// in the "kid" header; verification accepts any key that has not been retired.
+type Keyring struct {
+	keys    map[string]*Key
+	order   []string
+	signing *Key
+}
+
+func New(signingKeyID string, configs []KeyConfig) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]*Key)}
+	for _, cfg := range configs {
+		key, err := loadKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.ID, err)
+		}
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+		kr.order = append(kr.order, key.ID)
+	}
+
+	if signingKeyID == "" {
+		for _, id := range kr.order {
+			if key := kr.keys[id]; key.Status == StatusActive && key.signKey != nil {
+				signingKeyID = id
+				break
+			}
+		}
+	}
+	signing, ok := kr.keys[signingKeyID]
+	if !ok {
+		return nil, errors.New("no signing key configured")
+	}
+	if signing.Status != StatusActive || signing.signKey == nil {
+		return nil, fmt.Errorf("key %q cannot be used for signing", signingKeyID)
+	}
+	kr.signing = signing
+
+	return kr, nil
+}
+
+// Sign returns the signed token for claims using the current signing key.
+func (kr *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.signing.method, claims)
+	token.Header["kid"] = kr.signing.ID
+	return token.SignedString(kr.signing.signKey)
+}
+
+// Parse verifies tokenStr against the key named by its "kid" header and decodes it into claims.
+func (kr *Keyring) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := kr.keys[kid]
+		if !ok || key.Status == StatusRetired {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
+	})
+}
+
+func loadKey(cfg KeyConfig) (*Key, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	key := &Key{ID: cfg.ID, Algorithm: cfg.Algorithm, Status: cfg.Status}
+	if key.Status == "" {
+		key.Status = StatusActive
+	}
+	if key.Status != StatusActive && key.Status != StatusRetired {
+		return nil, fmt.Errorf("unknown status %q", key.Status)
+	}
+
+	var err error
+	switch cfg.Algorithm {
+	case "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("secret is required for HS256")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		err = loadRSA(key, cfg)
+	case "EdDSA":
+		key.method = EdDSA
+		err = loadEd25519(key, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func loadRSA(key *Key, cfg KeyConfig) error {
+	if cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		key.signKey = privateKey
+		key.verifyKey = &privateKey.PublicKey
+		return nil
+	}
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return err
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		key.verifyKey = publicKey
+		return nil
+	}
+	return errors.New("private_key_file or public_key_file is required for RS256")
+}
+
+func loadEd25519(key *Key, cfg KeyConfig) error {
+	if cfg.PrivateKeyFile != "" {
+		parsed, err := parsePEMFile(cfg.PrivateKeyFile, x509.ParsePKCS8PrivateKey)
+		if err != nil {
+			return err
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return errors.New("private key is not an Ed25519 key")
+		}
+		key.signKey = privateKey
+		key.verifyKey = privateKey.Public()
+		return nil
+	}
+	if cfg.PublicKeyFile != "" {
+		parsed, err := parsePEMFile(cfg.PublicKeyFile, x509.ParsePKIXPublicKey)
+		if err != nil {
+			return err
+		}
+		publicKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("public key is not an Ed25519 key")
+		}
+		key.verifyKey = publicKey
+		return nil
+	}
+	return errors.New("private_key_file or public_key_file is required for EdDSA")
+}
+
+func parsePEMFile[T any](path string, parse func([]byte) (T, error)) (T, error) {
+	var zero T
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return zero, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return zero, errors.New("invalid PEM data in " + path)
+	}
+	return parse(block.Bytes)
+}
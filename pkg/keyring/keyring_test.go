@@ -0,0 +1,250 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testKeys holds PEM files of freshly generated keys.
+type testKeys struct {
+	rsaPrivate, rsaPublic string
+	edPrivate, edPublic   string
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPrivate, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, err := x509.MarshalPKIXPublicKey(edPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKeys{
+		rsaPrivate: writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		rsaPublic:  writePEM(t, dir, "rsa.pub.pem", "PUBLIC KEY", rsaPublic),
+		edPrivate:  writePEM(t, dir, "ed.pem", "PRIVATE KEY", edPrivate),
+		edPublic:   writePEM(t, dir, "ed.pub.pem", "PUBLIC KEY", edPublic),
+	}
+}
+
+func newKeyring(t *testing.T, signingKeyID string, configs ...KeyConfig) *Keyring {
+	t.Helper()
+	kr, err := New(signingKeyID, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+func testClaims() *jwt.StandardClaims {
+	return &jwt.StandardClaims{Subject: "42", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func parseSubject(kr *Keyring, token string) (string, error) {
+	claims := &jwt.StandardClaims{}
+	if _, err := kr.Parse(token, claims); err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+func TestSignAndParse(t *testing.T) {
+	keys := newTestKeys(t)
+	for _, cfg := range []KeyConfig{
+		{ID: "hs", Algorithm: "HS256", Secret: "a-long-test-secret"},
+		{ID: "rs", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate},
+		{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivate},
+	} {
+		kr := newKeyring(t, "", cfg)
+		token, err := kr.Sign(testClaims())
+		if err != nil {
+			t.Fatalf("%s: Sign: %v", cfg.Algorithm, err)
+		}
+
+		parsed, _ := jwt.Parse(token, nil)
+		if parsed == nil || parsed.Header["kid"] != cfg.ID || parsed.Header["alg"] != cfg.Algorithm {
+			t.Errorf("%s: token header = %v, want kid %q", cfg.Algorithm, parsed.Header, cfg.ID)
+		}
+
+		if subject, err := parseSubject(kr, token); err != nil || subject != "42" {
+			t.Errorf("%s: Parse = %q, %v, want subject 42", cfg.Algorithm, subject, err)
+		}
+
+		parts := strings.Split(token, ".")
+		tampered := parts[0] + "." + parts[1] + "." + flipFirstChar(parts[2])
+		if _, err := parseSubject(kr, tampered); err == nil {
+			t.Errorf("%s: Parse accepted a token with a tampered signature", cfg.Algorithm)
+		}
+	}
+}
+
+func flipFirstChar(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
+
+func TestRotation(t *testing.T) {
+	keys := newTestKeys(t)
+	oldKey := KeyConfig{ID: "old", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate}
+	newKey := KeyConfig{ID: "new", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivate}
+
+	before := newKeyring(t, "old", oldKey)
+	token, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := newKeyring(t, "new", oldKey, newKey)
+	if _, err := parseSubject(after, token); err != nil {
+		t.Errorf("token of the previous signing key rejected after rotation: %v", err)
+	}
+
+	oldKey.Status = StatusRetired
+	retired := newKeyring(t, "new", oldKey, newKey)
+	// jwt-go v3 wraps the key lookup error without supporting errors.Is
+	_, err = parseSubject(retired, token)
+	if validation, ok := err.(*jwt.ValidationError); !ok || validation.Inner != ErrUnknownKey {
+		t.Errorf("token of a retired key: Parse = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestNewRejectsUnusableSigningKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	tests := []struct {
+		name         string
+		signingKeyID string
+		configs      []KeyConfig
+	}{
+		{"no keys", "", nil},
+		{"unknown signing key", "missing", []KeyConfig{{ID: "hs", Algorithm: "HS256", Secret: "secret"}}},
+		{"retired signing key", "hs", []KeyConfig{{ID: "hs", Algorithm: "HS256", Secret: "secret", Status: StatusRetired}}},
+		{"public key only", "ed", []KeyConfig{{ID: "ed", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic}}},
+		{"duplicate id", "hs", []KeyConfig{
+			{ID: "hs", Algorithm: "HS256", Secret: "secret"},
+			{ID: "hs", Algorithm: "HS256", Secret: "other"},
+		}},
+		{"unsupported algorithm", "", []KeyConfig{{ID: "es", Algorithm: "ES256"}}},
+		{"missing secret", "", []KeyConfig{{ID: "hs", Algorithm: "HS256"}}},
+		{"RSA file for EdDSA", "", []KeyConfig{{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: keys.rsaPrivate}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.signingKeyID, tt.configs); err == nil {
+			t.Errorf("%s: New succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestVerifyOnlyKey(t *testing.T) {
+	keys := newTestKeys(t)
+	signer := newKeyring(t, "ed", KeyConfig{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivate})
+	token, err := signer.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := newKeyring(t, "hs",
+		KeyConfig{ID: "hs", Algorithm: "HS256", Secret: "secret"},
+		KeyConfig{ID: "ed", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic},
+	)
+	if _, err := parseSubject(verifier, token); err != nil {
+		t.Errorf("public-key-only EdDSA key rejected a valid token: %v", err)
+	}
+}
+
+// TestAlgorithmConfusion checks that a token is only verified with the algorithm of the key
+// its "kid" names, so a public key can never be used as an HMAC secret and "none" is refused.
+func TestAlgorithmConfusion(t *testing.T) {
+	keys := newTestKeys(t)
+	kr := newKeyring(t, "rs",
+		KeyConfig{ID: "rs", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivate},
+		KeyConfig{ID: "ed", Algorithm: "EdDSA", PublicKeyFile: keys.edPublic},
+		KeyConfig{ID: "hs", Algorithm: "HS256", Secret: "a-long-test-secret"},
+	)
+	rsaPublicPEM, err := os.ReadFile(keys.rsaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublicPEM, err := os.ReadFile(keys.edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forge := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 signed with the RSA public key", forge(jwt.SigningMethodHS256, "rs", rsaPublicPEM)},
+		{"HS256 signed with the Ed25519 public key", forge(jwt.SigningMethodHS256, "ed", edPublicPEM)},
+		{"alg none for an RSA key", forge(jwt.SigningMethodNone, "rs", jwt.UnsafeAllowNoneSignatureType)},
+		{"alg none for an HMAC key", forge(jwt.SigningMethodNone, "hs", jwt.UnsafeAllowNoneSignatureType)},
+		{"HS256 secret used under another kid", forge(jwt.SigningMethodHS256, "rs", []byte("a-long-test-secret"))},
+		{"no kid", forge(jwt.SigningMethodHS256, "", []byte("a-long-test-secret"))},
+		{"unknown kid", forge(jwt.SigningMethodHS256, "other", []byte("a-long-test-secret"))},
+	}
+	for _, tt := range tests {
+		if subject, err := parseSubject(kr, tt.token); err == nil {
+			t.Errorf("%s: Parse accepted the token, subject %q", tt.name, subject)
+		}
+	}
+}
+
+func TestEdDSARejectsWrongKeyTypes(t *testing.T) {
+	if _, err := EdDSA.Sign("payload", []byte("secret")); !errors.Is(err, jwt.ErrInvalidKeyType) {
+		t.Errorf("Sign with an HMAC secret = %v, want ErrInvalidKeyType", err)
+	}
+	if err := EdDSA.Verify("payload", "c2ln", []byte("secret")); !errors.Is(err, jwt.ErrInvalidKeyType) {
+		t.Errorf("Verify with an HMAC secret = %v, want ErrInvalidKeyType", err)
+	}
+}
@@ -0,0 +1,285 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the authorization code
+// flow with PKCE and ID token verification against the provider's published keys.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// ProviderConfig configures one identity provider. Issuer is used for discovery and must match
+// the "iss" claim of the ID tokens it issues.
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	RedirectURL  string `mapstructure:"redirect_url"`
+	Scopes       []string
+}
+
+// Claims are the ID token claims the blog cares about.
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Name          string   `json:"name"`
+}
+
+// clockSkew is how far the provider's clock may be ahead of ours.
+const clockSkew = time.Minute
+
+func (c *Claims) Valid() error {
+	if c.ExpiresAt == 0 || time.Now().Add(-clockSkew).Unix() > c.ExpiresAt {
+		return errors.New("token is expired")
+	}
+	return nil
+}
+
+// audience is the "aud" claim, which may be a single string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one identity provider. The discovery document and signing keys
+// are fetched on first use and cached, so the blog starts even when a provider is down.
+type Provider struct {
+	Config ProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]interface{}
+}
+
+func NewProvider(config ProviderConfig) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{Config: config, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// GenerateVerifier returns a random PKCE code verifier (RFC 7636); it doubles as a source
+// for state and nonce values.
+func GenerateVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL returns the URL to send the user to, using the S256 challenge for verifier.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.Config.ClientID)
+	params.Set("redirect_uri", p.Config.RedirectURL)
+	params.Set("scope", strings.Join(p.Config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token claims.
+func (p *Provider) Exchange(code, verifier, nonce string) (*Claims, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &tokenResp); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, errors.New("token exchange: no id_token in response")
+	}
+
+	return p.verifyIDToken(tokenResp.IDToken, nonce)
+}
+
+func (p *Provider) verifyIDToken(rawIDToken, nonce string) (*Claims, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	parser := jwt.Parser{ValidMethods: []string{"RS256", "ES256"}}
+	token, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidIDToken
+	}
+
+	if claims.Issuer != doc.Issuer || !claims.Audience.contains(p.Config.ClientID) {
+		return nil, ErrInvalidIDToken
+	}
+	if claims.Subject == "" || claims.Nonce != nonce {
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+func (p *Provider) discover() (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(p.Config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var doc discoveryDocument
+	if err := p.doJSON(req, &doc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if doc.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match configured %q", doc.Issuer, p.Config.Issuer)
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// key returns the provider's public key with the given ID, refetching the key set once
+// when the ID is unknown, since providers rotate their keys.
+func (p *Provider) key(kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := p.refreshKeys(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (p *Provider) refreshKeys() error {
+	doc, err := p.discover()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, doc.JWKSURI, nil)
+	if err != nil {
+		return err
+	}
+	var set jsonWebKeySet
+	if err := p.doJSON(req, &set); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Provider) doJSON(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, req.URL.Host)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,102 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedList looks up passwords in a local file of SHA-1 hashes of breached passwords, one
+// uppercase hex hash per line, optionally followed by ":count", sorted by hash. This is the
+// format of the "ordered by hash" Pwned Passwords download. The file is binary searched on
+// disk, so it can be far larger than memory.
+type BreachedList struct {
+	file *os.File
+	size int64
+}
+
+func OpenBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &BreachedList{file: file, size: info.Size()}, nil
+}
+
+// Contains reports whether the password's SHA-1 hash is in the list.
+func (b *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := []byte(strings.ToUpper(hex.EncodeToString(sum[:])))
+
+	// Search the lines that start in [lo, hi); lo is always the start of a line
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := b.lineStartFrom(mid, lo)
+		if err != nil {
+			return false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		line, end, err := b.readLine(start)
+		if err != nil {
+			return false, err
+		}
+		switch cmp := bytes.Compare(lineHash(line), target); {
+		case cmp == 0:
+			return true, nil
+		case cmp < 0:
+			lo = end
+		default:
+			hi = start
+		}
+	}
+	return false, nil
+}
+
+// lineStartFrom returns the start of the first line that begins at or after offset.
+func (b *BreachedList) lineStartFrom(offset, lo int64) (int64, error) {
+	if offset == lo {
+		return lo, nil
+	}
+	reader := bufio.NewReader(io.NewSectionReader(b.file, offset-1, b.size-offset+1))
+	skipped, err := reader.ReadSlice('\n')
+	if err == io.EOF {
+		return b.size, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+// readLine returns the line at start without its line ending, and the start of the next line.
+func (b *BreachedList) readLine(start int64) ([]byte, int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(b.file, start, b.size-start))
+	line, err := reader.ReadSlice('\n')
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	end := start + int64(len(line))
+	return bytes.TrimRight(line, "\r\n"), end, nil
+}
+
+// lineHash returns the hash part of a line, dropping a ":count" suffix.
+func lineHash(line []byte) []byte {
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return bytes.ToUpper(line)
+}
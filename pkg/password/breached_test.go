@@ -0,0 +1,109 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeBreachedList writes the sorted SHA-1 hashes of passwords in the Pwned Passwords
+// format and opens the file.
+func writeBreachedList(t *testing.T, passwords []string, lineEnding string, withCounts bool) *BreachedList {
+	t.Helper()
+	hashes := make([]string, len(passwords))
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hashes[i] = strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+	sort.Strings(hashes)
+
+	var b strings.Builder
+	for i, hash := range hashes {
+		b.WriteString(hash)
+		if withCounts {
+			fmt.Fprintf(&b, ":%d", i+1)
+		}
+		b.WriteString(lineEnding)
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := OpenBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { list.file.Close() })
+	return list
+}
+
+func TestBreachedListContains(t *testing.T) {
+	var breached []string
+	for i := 0; i < 500; i++ {
+		breached = append(breached, fmt.Sprintf("breached-%d", i))
+	}
+
+	for _, format := range []struct {
+		name       string
+		lineEnding string
+		withCounts bool
+	}{
+		{"hashes only", "\n", false},
+		{"with counts", "\n", true},
+		{"CRLF with counts", "\r\n", true},
+	} {
+		list := writeBreachedList(t, breached, format.lineEnding, format.withCounts)
+		for _, password := range breached {
+			found, err := list.Contains(password)
+			if err != nil {
+				t.Fatalf("%s: Contains(%q): %v", format.name, password, err)
+			}
+			if !found {
+				t.Errorf("%s: Contains(%q) = false, want true", format.name, password)
+			}
+		}
+		for i := 0; i < 500; i++ {
+			password := fmt.Sprintf("safe-%d", i)
+			found, err := list.Contains(password)
+			if err != nil {
+				t.Fatalf("%s: Contains(%q): %v", format.name, password, err)
+			}
+			if found {
+				t.Errorf("%s: Contains(%q) = true, want false", format.name, password)
+			}
+		}
+	}
+}
+
+func TestBreachedListWithoutTrailingNewline(t *testing.T) {
+	sum := sha1.Sum([]byte("only"))
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.ToUpper(hex.EncodeToString(sum[:]))+":3"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := OpenBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.file.Close()
+
+	if found, err := list.Contains("only"); err != nil || !found {
+		t.Errorf("Contains(only) = %t, %v, want true", found, err)
+	}
+	if found, err := list.Contains("other"); err != nil || found {
+		t.Errorf("Contains(other) = %t, %v, want false", found, err)
+	}
+}
+
+func TestBreachedListEmpty(t *testing.T) {
+	list := writeBreachedList(t, nil, "\n", false)
+	if found, err := list.Contains("password"); err != nil || found {
+		t.Errorf("Contains on an empty list = %t, %v, want false", found, err)
+	}
+}
@@ -0,0 +1,185 @@
+// Package password hashes passwords with argon2id or bcrypt. Hashes are stored as self-describing
+// strings: argon2id in PHC format ($argon2id$v=19$m=65536,t=3,p=2$salt$hash) and bcrypt in its
+// own $2a$ format, so a hash can be verified whatever the current configuration is.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var ErrMismatch = errors.New("password does not match")
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Argon2Config holds the argon2id parameters; Memory is in KiB.
+type Argon2Config struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32 `mapstructure:"salt_length"`
+	KeyLength   uint32 `mapstructure:"key_length"`
+}
+
+type BcryptConfig struct {
+	Cost int
+}
+
+// Config selects the algorithm new hashes are created with. Parameters left at zero
+// take the defaults.
+type Config struct {
+	Algorithm string
+	Argon2id  Argon2Config
+	Bcrypt    BcryptConfig
+}
+
+// Defaults follow the OWASP recommendations for argon2id and bcrypt.
+var (
+	DefaultArgon2 = Argon2Config{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+	DefaultBcrypt = BcryptConfig{Cost: 12}
+)
+
+// Hasher creates hashes with the configured algorithm and verifies hashes of either algorithm.
+type Hasher struct {
+	algorithm string
+	argon2    Argon2Config
+	bcrypt    BcryptConfig
+}
+
+func New(cfg Config) (*Hasher, error) {
+	h := &Hasher{algorithm: cfg.Algorithm, argon2: cfg.Argon2id, bcrypt: cfg.Bcrypt}
+	if h.algorithm == "" {
+		h.algorithm = AlgorithmArgon2id
+	}
+	if h.algorithm != AlgorithmArgon2id && h.algorithm != AlgorithmBcrypt {
+		return nil, fmt.Errorf("unsupported algorithm %q", h.algorithm)
+	}
+
+	if h.argon2.Memory == 0 {
+		h.argon2.Memory = DefaultArgon2.Memory
+	}
+	if h.argon2.Iterations == 0 {
+		h.argon2.Iterations = DefaultArgon2.Iterations
+	}
+	if h.argon2.Parallelism == 0 {
+		h.argon2.Parallelism = DefaultArgon2.Parallelism
+	}
+	if h.argon2.SaltLength == 0 {
+		h.argon2.SaltLength = DefaultArgon2.SaltLength
+	}
+	if h.argon2.KeyLength == 0 {
+		h.argon2.KeyLength = DefaultArgon2.KeyLength
+	}
+	if h.bcrypt.Cost == 0 {
+		h.bcrypt.Cost = DefaultBcrypt.Cost
+	}
+	if h.bcrypt.Cost < bcrypt.MinCost || h.bcrypt.Cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost %d out of range", h.bcrypt.Cost)
+	}
+	return h, nil
+}
+
+// Hash returns a new hash of password with the configured algorithm and parameters.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcrypt.Cost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks password against a hash of either algorithm. It returns ErrMismatch
+// if the password is wrong and ErrUnknownFormat if the hash cannot be parsed.
+func (h *Hasher) Verify(password, hash string) error {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+
+	params, salt, key, err := parseArgon2(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether hash was created with another algorithm or other parameters
+// than the ones configured, so it should be replaced the next time the password is known.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if isBcrypt(hash) {
+		if h.algorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.bcrypt.Cost
+	}
+
+	if h.algorithm != AlgorithmArgon2id {
+		return true
+	}
+	params, salt, key, err := parseArgon2(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.argon2.Memory ||
+		params.Iterations != h.argon2.Iterations ||
+		params.Parallelism != h.argon2.Parallelism ||
+		uint32(len(salt)) != h.argon2.SaltLength ||
+		uint32(len(key)) != h.argon2.KeyLength
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// parseArgon2 splits a PHC argon2id string into its parameters, salt and key.
+func parseArgon2(hash string) (Argon2Config, []byte, []byte, error) {
+	var params Argon2Config
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownFormat
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,164 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2 keeps the tests quick; the parameters are stored in the hash, so any values work.
+var fastArgon2 = Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newHasher(t *testing.T, cfg Config) *Hasher {
+	t.Helper()
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	h := newHasher(t, Config{Algorithm: AlgorithmArgon2id, Argon2id: fastArgon2})
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "$argon2id$v=19$m=1024,t=1,p=1$"; !strings.HasPrefix(hash, want) {
+		t.Errorf("hash %q does not start with %q", hash, want)
+	}
+	if err := h.Verify("correct horse", hash); err != nil {
+		t.Errorf("Verify with the right password: %v", err)
+	}
+	if err := h.Verify("correct horsf", hash); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify with a wrong password = %v, want ErrMismatch", err)
+	}
+
+	other, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("two hashes of the same password are equal, want a random salt")
+	}
+}
+
+// TestArgon2idReferenceHash pins the PHC encoding, so a change to the key derivation or the
+// encoding cannot silently invalidate the stored hashes.
+func TestArgon2idReferenceHash(t *testing.T) {
+	h := newHasher(t, Config{})
+	const hash = "$argon2id$v=19$m=1024,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$FiU+I6KbINfHoMBfkVDnS6qmzxgy7cg41IT8JQoCvvk"
+	if err := h.Verify("password", hash); err != nil {
+		t.Errorf("Verify of the reference hash: %v", err)
+	}
+	if err := h.Verify("Password", hash); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify of the reference hash with a wrong password = %v, want ErrMismatch", err)
+	}
+}
+
+func TestBcryptHashAndVerify(t *testing.T) {
+	h := newHasher(t, Config{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptConfig{Cost: bcrypt.MinCost}})
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isBcrypt(hash) {
+		t.Errorf("hash %q is not a bcrypt hash", hash)
+	}
+	if err := h.Verify("correct horse", hash); err != nil {
+		t.Errorf("Verify with the right password: %v", err)
+	}
+	if err := h.Verify("correct horsf", hash); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify with a wrong password = %v, want ErrMismatch", err)
+	}
+}
+
+// TestVerifyAcrossAlgorithms checks that hashes stay valid after the configured algorithm changes.
+func TestVerifyAcrossAlgorithms(t *testing.T) {
+	argon := newHasher(t, Config{Algorithm: AlgorithmArgon2id, Argon2id: fastArgon2})
+	bcryptHasher := newHasher(t, Config{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptConfig{Cost: bcrypt.MinCost}})
+
+	argonHash, err := argon.Hash("secret password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcryptHasher.Hash("secret password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcryptHasher.Verify("secret password", argonHash); err != nil {
+		t.Errorf("bcrypt hasher rejected an argon2id hash: %v", err)
+	}
+	if err := argon.Verify("secret password", bcryptHash); err != nil {
+		t.Errorf("argon2id hasher rejected a bcrypt hash: %v", err)
+	}
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	h := newHasher(t, Config{Argon2id: fastArgon2})
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$",
+	} {
+		if err := h.Verify("password", hash); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Verify(%q) = %v, want ErrUnknownFormat", hash, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := newHasher(t, Config{Algorithm: AlgorithmArgon2id, Argon2id: fastArgon2})
+	bcryptHasher := newHasher(t, Config{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptConfig{Cost: bcrypt.MinCost}})
+
+	argonHash, err := argon.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcryptHasher.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := fastArgon2
+	stronger.Iterations = 2
+	strongerArgon := newHasher(t, Config{Algorithm: AlgorithmArgon2id, Argon2id: stronger})
+	costlierBcrypt := newHasher(t, Config{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptConfig{Cost: bcrypt.MinCost + 1}})
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   string
+		want   bool
+	}{
+		{"same argon2id parameters", argon, argonHash, false},
+		{"other argon2id parameters", strongerArgon, argonHash, true},
+		{"argon2id hash, bcrypt configured", bcryptHasher, argonHash, true},
+		{"same bcrypt cost", bcryptHasher, bcryptHash, false},
+		{"other bcrypt cost", costlierBcrypt, bcryptHash, true},
+		{"bcrypt hash, argon2id configured", argon, bcryptHash, true},
+		{"unparseable hash", argon, "garbage", true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	if _, err := New(Config{Algorithm: "md5"}); err == nil {
+		t.Error("New accepted an unsupported algorithm")
+	}
+	if _, err := New(Config{Algorithm: AlgorithmBcrypt, Bcrypt: BcryptConfig{Cost: bcrypt.MaxCost + 1}}); err == nil {
+		t.Error("New accepted a bcrypt cost out of range")
+	}
+}
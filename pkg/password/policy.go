@@ -0,0 +1,118 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const defaultMinLength = 8
+
+// minSimilarLength is the shortest piece of personal information a password is compared with;
+// shorter ones such as a two-letter name would reject too many good passwords.
+const minSimilarLength = 4
+
+// PolicyConfig configures which passwords are accepted. BreachedHashesFile is optional,
+// see BreachedList for its format.
+type PolicyConfig struct {
+	MinLength          int      `mapstructure:"min_length"`
+	Banned             []string `mapstructure:"banned"`
+	RejectPersonalInfo bool     `mapstructure:"reject_personal_info"`
+	BreachedHashesFile string   `mapstructure:"breached_hashes_file"`
+}
+
+// PolicyError explains why a password was rejected. Code is machine-readable.
+type PolicyError struct {
+	Code    string
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return "password " + e.Message
+}
+
+// Policy decides whether a password may be set.
+type Policy struct {
+	minLength          int
+	banned             map[string]bool
+	rejectPersonalInfo bool
+	breached           *BreachedList
+}
+
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	p := &Policy{
+		minLength:          cfg.MinLength,
+		banned:             make(map[string]bool),
+		rejectPersonalInfo: cfg.RejectPersonalInfo,
+	}
+	if p.minLength <= 0 {
+		p.minLength = defaultMinLength
+	}
+	for _, banned := range cfg.Banned {
+		p.banned[strings.ToLower(banned)] = true
+	}
+	if cfg.BreachedHashesFile != "" {
+		breached, err := OpenBreachedList(cfg.BreachedHashesFile)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = breached
+	}
+	return p, nil
+}
+
+// Check returns a *PolicyError if password is not acceptable. personalInfo holds values
+// such as the user's name and email address that the password must not resemble.
+func (p *Policy) Check(password string, personalInfo ...string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return &PolicyError{"too_short", fmt.Sprintf("must be at least %d characters", p.minLength)}
+	}
+
+	lower := strings.ToLower(password)
+	if p.banned[lower] {
+		return &PolicyError{"banned", "is too common"}
+	}
+
+	if p.rejectPersonalInfo {
+		for _, info := range personalFragments(personalInfo) {
+			if strings.Contains(lower, info) || strings.Contains(info, lower) {
+				return &PolicyError{"too_similar", "must not contain your name or email address"}
+			}
+		}
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return &PolicyError{"breached", "has appeared in a data breach, choose another one"}
+		}
+	}
+	return nil
+}
+
+// personalFragments splits names and email addresses into the lowercase pieces a password
+// is compared with: the whole value, the local part of an email, and every word of a name.
+func personalFragments(personalInfo []string) []string {
+	var fragments []string
+	add := func(s string) {
+		if utf8.RuneCountInString(s) >= minSimilarLength {
+			fragments = append(fragments, s)
+		}
+	}
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+		add(info)
+		if local, _, ok := strings.Cut(info, "@"); ok {
+			add(local)
+		}
+		for _, word := range strings.FieldsFunc(info, func(r rune) bool {
+			return strings.ContainsRune(" .-_+@", r)
+		}) {
+			add(word)
+		}
+	}
+	return fragments
+}
@@ -0,0 +1,78 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := NewPolicy(PolicyConfig{
+		MinLength:          10,
+		Banned:             []string{"Password123!"},
+		RejectPersonalInfo: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	personal := []string{"Ada Lovelace", "ada.lovelace@example.com"}
+	tests := []struct {
+		password string
+		code     string
+	}{
+		{"short", "too_short"},
+		{"ääääääääää", ""},
+		{"password123!", "banned"},
+		{"my-lovelace-pass", "too_similar"},
+		{"ada.lovelace@example.com", "too_similar"},
+		{"Lovelace", "too_short"},
+		{"correct horse battery", ""},
+		{"adamantine staple", ""},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.password, personal...)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("Check(%q) = %v, want accepted", tt.password, err)
+			}
+			continue
+		}
+		var policyErr *PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Code != tt.code {
+			t.Errorf("Check(%q) = %v, want code %q", tt.password, err, tt.code)
+		}
+	}
+}
+
+func TestPolicyBreached(t *testing.T) {
+	list := writeBreachedList(t, []string{"hunter2hunter2"}, "\n", true)
+	policy, err := NewPolicy(PolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.breached = list
+
+	var policyErr *PolicyError
+	if err := policy.Check("hunter2hunter2"); !errors.As(err, &policyErr) || policyErr.Code != "breached" {
+		t.Errorf("Check of a breached password = %v, want code breached", err)
+	}
+	if err := policy.Check("hunter3hunter3"); err != nil {
+		t.Errorf("Check of a password not in the list = %v, want accepted", err)
+	}
+}
+
+func TestPolicyDefaults(t *testing.T) {
+	policy, err := NewPolicy(PolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Check("1234567"); err == nil {
+		t.Error("Check accepted a 7 character password with the default minimum length")
+	}
+	if err := policy.Check("Ada Lovelace", "Ada Lovelace"); err != nil {
+		t.Errorf("Check = %v, want personal info allowed unless configured", err)
+	}
+	if _, err := NewPolicy(PolicyConfig{BreachedHashesFile: "/nonexistent/breached.txt"}); err == nil {
+		t.Error("NewPolicy accepted a missing breached hashes file")
+	}
+}
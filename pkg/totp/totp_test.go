@@ -0,0 +1,131 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890" in base32.
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// rfcVectors are the SHA-1 test vectors of RFC 6238 appendix B, truncated to six digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestValidateRFCVectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		step, ok := Validate(rfcSecret, v.code, time.Unix(v.unix, 0))
+		if !ok {
+			t.Errorf("Validate(%d, %s) rejected the RFC 6238 code", v.unix, v.code)
+			continue
+		}
+		if want := v.unix / 30; step != want {
+			t.Errorf("Validate(%d, %s) step = %d, want %d", v.unix, v.code, step, want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	code := rfcVectors[1].code
+
+	for _, offset := range []time.Duration{-Period, 0, Period} {
+		if _, ok := Validate(rfcSecret, code, at.Add(offset)); !ok {
+			t.Errorf("code rejected %s from its step, want accepted within one step", offset)
+		}
+	}
+	for _, offset := range []time.Duration{-2 * Period, 2 * Period} {
+		if _, ok := Validate(rfcSecret, code, at.Add(offset)); ok {
+			t.Errorf("code accepted %s from its step, want rejected", offset)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfcSecret, "287083"},
+		{"too short", rfcSecret, "28708"},
+		{"too long", rfcSecret, "2870820"},
+		{"empty code", rfcSecret, ""},
+		{"invalid secret", "not base32!", "287082"},
+	}
+	for _, tt := range tests {
+		if _, ok := Validate(tt.secret, tt.code, at); ok {
+			t.Errorf("%s: Validate accepted %q", tt.name, tt.code)
+		}
+	}
+}
+
+func TestValidateNormalizesSecret(t *testing.T) {
+	secret := " " + strings.ToLower(rfcSecret) + " "
+	if _, ok := Validate(secret, "287082", time.Unix(59, 0)); !ok {
+		t.Error("Validate rejected a lowercase secret with surrounding spaces")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %s", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+
+	other, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == secret {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+
+	now := time.Now()
+	if _, ok := Validate(secret, generate(key, now.Unix()/30), now); !ok {
+		t.Error("Validate rejected the current code of a generated secret")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("Go Blog", "a@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("URI starts with %s://%s, want otpauth://totp", uri.Scheme, uri.Host)
+	}
+	if want := "/Go Blog:a@example.com"; uri.Path != want {
+		t.Errorf("label = %q, want %q", uri.Path, want)
+	}
+
+	params := uri.Query()
+	for key, want := range map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "Go Blog",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := params.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}